@@ -1,52 +1,105 @@
 package api
 
 import (
-	"copuchat/internal/redis"
+	"copuchat/internal/store"
 	"copuchat/internal/ws"
+	"errors"
 	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/labstack/echo/v5"
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/models"
 )
 
-func Routes(app *pocketbase.PocketBase) []echo.Route {
+func Routes(app *pocketbase.PocketBase, db store.Store) []echo.Route {
+	hubs := ws.NewRegistry(db)
+
 	return []echo.Route{
-		wsRoomRoute(app),
-		postRoomTopicRoute(app),
-		getRoomActiveUsersRoute(app),
-		getSubRoomsRoute(app),
+		wsRoomRoute(app, db, hubs),
+		getStatsRoute(app, hubs),
+		postRoomTopicRoute(app, db),
+		getTopicHistoryRoute(app, db),
+		getTopicPolicyRoute(app, db),
+		putTopicPolicyRoute(app, db),
+		deleteTopicPolicyRoute(app, db),
+		getRoomActiveUsersRoute(app, db),
+		getRoomPresenceRoute(app, db),
+		getSubRoomsRoute(app, db),
+		getMessagesRoute(app, db),
+		getThreadRoute(app, db),
+		searchRoute(app, db),
+		getMessageEditsRoute(app, db),
+		getModeratorsRoute(app, db),
+		postModeratorRoute(app, db),
+		deleteModeratorRoute(app, db),
+		getRetentionRoute(app, db),
+		putRetentionRoute(app, db),
+		deleteRetentionRoute(app, db),
+		postSeedRoute(app, db),
 	}
 }
 
-func wsRoomRoute(app *pocketbase.PocketBase) echo.Route {
+func wsRoomRoute(app *pocketbase.PocketBase, db store.Store, hubs *ws.Registry) echo.Route {
 	return echo.Route{
 		Method: http.MethodGet,
 		Path:   "/ws/*",
 		Handler: func(c echo.Context) error {
-			userName := c.QueryParam("userName") // TODO: or is authenticated.
+			// Authenticated users chat as their user name, anonymous ones as the name they pick.
+			userName := c.QueryParam("userName")
+			record := authRecord(c)
+			if record != nil {
+				userName = record.Username()
+			}
 			if userName == "" {
 				return echo.NewHTTPError(http.StatusBadRequest, "missing userName")
 			}
+			lastID := c.QueryParam("lastId")
+			if lastID != "" {
+				if _, _, err := store.ParseID(lastID); err != nil {
+					return echo.NewHTTPError(http.StatusBadRequest, "invalid lastId")
+				}
+			}
 			roomName := c.PathParam("*")
-			ws.Handler(roomName, userName).ServeHTTP(c.Response(), c.Request())
+			hubs.Handler(roomName, userName, record != nil, lastID).ServeHTTP(c.Response(), c.Request())
 
 			return nil
 		},
 		Middlewares: []echo.MiddlewareFunc{
 			apis.ActivityLogger(app),
-			authUserName(),
+			canonicalRoom(),
+			requireStore(db),
+			loadTokenAuth(app),
+		},
+	}
+}
+
+func getStatsRoute(app *pocketbase.PocketBase, hubs *ws.Registry) echo.Route {
+	return echo.Route{
+		Method: http.MethodGet,
+		Path:   "/stats",
+		Handler: func(c echo.Context) error {
+			return c.JSON(http.StatusOK, hubs.Stats())
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.ActivityLogger(app),
+			apis.RequireAdminAuth(),
 		},
 	}
 }
 
-func postRoomTopicRoute(app *pocketbase.PocketBase) echo.Route {
+func postRoomTopicRoute(app *pocketbase.PocketBase, db store.Store) echo.Route {
 	return echo.Route{
 		Method: http.MethodPost,
 		Path:   "/topic/*",
 		Handler: func(c echo.Context) error {
 			roomName := c.PathParam("*")
+			userName := ""
+			if record := authRecord(c); record != nil {
+				userName = record.Username()
+			}
 			newTopicBytes, err := io.ReadAll(c.Request().Body)
 			if err != nil {
 				return err
@@ -55,7 +108,13 @@ func postRoomTopicRoute(app *pocketbase.PocketBase) echo.Route {
 			if newTopic == "" {
 				return echo.NewHTTPError(http.StatusBadRequest, "missing topic")
 			}
-			if err := redis.SetTopic(roomName, newTopic); err != nil {
+			admin, _ := c.Get(apis.ContextAdminKey).(*models.Admin)
+			_, err = ws.SetTopic(db, roomName, userName, newTopic, admin != nil)
+			var topicErr *ws.TopicError
+			if errors.As(err, &topicErr) {
+				return echo.NewHTTPError(http.StatusForbidden, echo.Map{"message": "topic change forbidden", "error": topicErr})
+			}
+			if err != nil {
 				return err
 			}
 
@@ -63,17 +122,47 @@ func postRoomTopicRoute(app *pocketbase.PocketBase) echo.Route {
 		},
 		Middlewares: []echo.MiddlewareFunc{
 			apis.ActivityLogger(app),
+			canonicalRoom(),
+			requireStore(db),
 		},
 	}
 }
 
-func getRoomActiveUsersRoute(app *pocketbase.PocketBase) echo.Route {
+func getTopicHistoryRoute(app *pocketbase.PocketBase, db store.Store) echo.Route {
+	return echo.Route{
+		Method: http.MethodGet,
+		Path:   "/topic_history/*",
+		Handler: func(c echo.Context) error {
+			roomName := c.PathParam("*")
+			limit := store.TopicHistoryMaxSize
+			if err := intParam(c, "limit", &limit); err != nil {
+				return err
+			}
+			if limit < 1 || limit > store.TopicHistoryMaxSize {
+				return echo.NewHTTPError(http.StatusBadRequest, "invalid limit")
+			}
+			history, err := db.GetTopicHistory(roomName, limit)
+			if err != nil {
+				return err
+			}
+
+			return c.JSON(http.StatusOK, history)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.ActivityLogger(app),
+			canonicalRoom(),
+			requireStore(db),
+		},
+	}
+}
+
+func getRoomActiveUsersRoute(app *pocketbase.PocketBase, db store.Store) echo.Route {
 	return echo.Route{
 		Method: http.MethodGet,
 		Path:   "/users/*",
 		Handler: func(c echo.Context) error {
 			roomName := c.PathParam("*")
-			userNames, err := redis.GetActiveUsers(roomName)
+			userNames, err := db.GetActiveUsers(roomName)
 			if err != nil {
 				return err
 			}
@@ -82,17 +171,44 @@ func getRoomActiveUsersRoute(app *pocketbase.PocketBase) echo.Route {
 		},
 		Middlewares: []echo.MiddlewareFunc{
 			apis.ActivityLogger(app),
+			canonicalRoom(),
+			requireStore(db),
+		},
+	}
+}
+
+func getRoomPresenceRoute(app *pocketbase.PocketBase, db store.Store) echo.Route {
+	return echo.Route{
+		Method: http.MethodGet,
+		Path:   "/presence/*",
+		Handler: func(c echo.Context) error {
+			roomName := c.PathParam("*")
+			online, err := db.GetOnlineUsers(roomName)
+			if err != nil {
+				return err
+			}
+			chatters, err := db.GetActiveUsers(roomName)
+			if err != nil {
+				return err
+			}
+
+			return c.JSON(http.StatusOK, store.Presence{Online: online, Chatters: chatters})
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.ActivityLogger(app),
+			canonicalRoom(),
+			requireStore(db),
 		},
 	}
 }
 
-func getSubRoomsRoute(app *pocketbase.PocketBase) echo.Route {
+func getSubRoomsRoute(app *pocketbase.PocketBase, db store.Store) echo.Route {
 	return echo.Route{
 		Method: http.MethodGet,
 		Path:   "/sub_rooms/*",
 		Handler: func(c echo.Context) error {
 			roomName := c.PathParam("*")
-			subRooms, err := redis.GetTopSubRooms(roomName)
+			subRooms, err := db.GetTopSubRooms(roomName)
 			if err != nil {
 				return err
 			}
@@ -101,6 +217,128 @@ func getSubRoomsRoute(app *pocketbase.PocketBase) echo.Route {
 		},
 		Middlewares: []echo.MiddlewareFunc{
 			apis.ActivityLogger(app),
+			canonicalRoom(),
+			requireStore(db),
 		},
 	}
 }
+
+func getMessagesRoute(app *pocketbase.PocketBase, db store.Store) echo.Route {
+	return echo.Route{
+		Method: http.MethodGet,
+		Path:   "/messages/*",
+		Handler: func(c echo.Context) error {
+			roomName := c.PathParam("*")
+			page, err := pageParams(c)
+			if err != nil {
+				return err
+			}
+			messages, err := db.GetMessages(roomName, page)
+			if err != nil {
+				return err
+			}
+
+			return c.JSON(http.StatusOK, messages)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.ActivityLogger(app),
+			canonicalRoom(),
+			requireStore(db),
+		},
+	}
+}
+
+func getThreadRoute(app *pocketbase.PocketBase, db store.Store) echo.Route {
+	return echo.Route{
+		Method: http.MethodGet,
+		Path:   "/thread/*",
+		Handler: func(c echo.Context) error {
+			roomName := c.PathParam("*")
+			id := c.QueryParam("id")
+			if _, _, err := store.ParseID(id); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "invalid id")
+			}
+			page, err := pageParams(c)
+			if err != nil {
+				return err
+			}
+			thread, err := db.GetThread(roomName, id, page)
+			if errors.Is(err, store.ErrNil) {
+				return echo.NewHTTPError(http.StatusNotFound, "message not found")
+			}
+			if err != nil {
+				return err
+			}
+
+			return c.JSON(http.StatusOK, thread)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.ActivityLogger(app),
+			canonicalRoom(),
+			requireStore(db),
+		},
+	}
+}
+
+func pageParams(c echo.Context) (store.Page, error) {
+	page := store.Page{Before: c.QueryParam("before"), After: c.QueryParam("after")}
+	if err := intParam(c, "limit", &page.Limit); err != nil {
+		return page, err
+	}
+	page, err := page.Normalize()
+	if err != nil {
+		return page, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return page, nil
+}
+
+func searchRoute(app *pocketbase.PocketBase, db store.Store) echo.Route {
+	return echo.Route{
+		Method: http.MethodGet,
+		Path:   "/search/*",
+		Handler: func(c echo.Context) error {
+			query := store.SearchQuery{
+				Text:    c.QueryParam("q"),
+				Room:    c.PathParam("*"),
+				Subtree: c.QueryParam("subtree") == "true",
+				Author:  c.QueryParam("author"),
+			}
+			if err := intParam(c, "offset", &query.Offset); err != nil {
+				return err
+			}
+			if err := intParam(c, "limit", &query.Limit); err != nil {
+				return err
+			}
+			query, err := query.Normalize()
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			results, err := db.Search(query)
+			if err != nil {
+				return err
+			}
+
+			return c.JSON(http.StatusOK, results)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.ActivityLogger(app),
+			canonicalRoom(),
+			requireStore(db),
+		},
+	}
+}
+
+func intParam(c echo.Context, name string, value *int) error {
+	param := c.QueryParam(name)
+	if param == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(param)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid "+name)
+	}
+	*value = n
+
+	return nil
+}
@@ -1,14 +1,74 @@
 package api
 
 import (
+	"copuchat/internal/store"
+	"errors"
+	"log"
+	"net/http"
+
 	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/models"
 )
 
-// TODO: check if userName is available with some generated token or something
-func authUserName() echo.MiddlewareFunc {
+// loadTokenAuth authenticates the request as the auth record of the token query param when the Authorization
+// header didn't, since browsers can't set headers on websocket requests.
+func loadTokenAuth(app *pocketbase.PocketBase) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token := c.QueryParam("token")
+			if token == "" || authRecord(c) != nil {
+				return next(c)
+			}
+			record, err := app.Dao().FindAuthRecordByToken(token, app.Settings().RecordAuthToken.Secret)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid token")
+			}
+			c.Set(apis.ContextAuthRecordKey, record)
+
+			return next(c)
+		}
+	}
+}
+
+// authRecord returns the auth record the request is authenticated as, nil when it isn't.
+func authRecord(c echo.Context) *models.Record {
+	record, _ := c.Get(apis.ContextAuthRecordKey).(*models.Record)
+
+	return record
+}
+
+func requireStore(db store.Store) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if err := db.Health(); err != nil {
+				log.Printf("api: %s\n", err)
+
+				return echo.NewHTTPError(http.StatusServiceUnavailable, "chat unavailable")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// canonicalRoom replaces the room path param with its canonical name, rejecting invalid names with the reason.
+func canonicalRoom() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			//userName := c.QueryParam("userName")
+			roomName, err := store.CanonicalRoom(c.PathParam("*"))
+			var roomErr *store.RoomNameError
+			if errors.As(err, &roomErr) {
+				return echo.NewHTTPError(http.StatusBadRequest, echo.Map{"message": "invalid room name", "error": roomErr})
+			}
+			params := c.PathParams()
+			for i := range params {
+				if params[i].Name == "*" {
+					params[i].Value = roomName
+				}
+			}
+			c.SetPathParams(params)
 
 			return next(c)
 		}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"copuchat/internal/store"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+func getMessageEditsRoute(app *pocketbase.PocketBase, db store.Store) echo.Route {
+	return echo.Route{
+		Method: http.MethodGet,
+		Path:   "/edits/*",
+		Handler: func(c echo.Context) error {
+			roomName := c.PathParam("*")
+			id := c.QueryParam("id")
+			if _, _, err := store.ParseID(id); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "invalid id")
+			}
+			message, err := db.GetMessage(roomName, id)
+			if errors.Is(err, store.ErrNil) {
+				return echo.NewHTTPError(http.StatusNotFound, "message not found")
+			}
+			if err != nil {
+				return err
+			}
+			// The history of a deleted message holds the text it was deleted for, only moderators can see it.
+			if message.Deleted {
+				isModerator, err := isRoomModerator(c, db, roomName)
+				if err != nil {
+					return err
+				}
+				if !isModerator {
+					return echo.NewHTTPError(http.StatusNotFound, "message not found")
+				}
+			}
+			edits, err := db.GetMessageEdits(roomName, id)
+			if err != nil {
+				return err
+			}
+
+			return c.JSON(http.StatusOK, edits)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.ActivityLogger(app),
+			canonicalRoom(),
+			requireStore(db),
+		},
+	}
+}
+
+// isRoomModerator reports whether the request is authenticated as an admin or as a moderator of roomName.
+func isRoomModerator(c echo.Context, db store.Store, roomName string) (bool, error) {
+	if admin, _ := c.Get(apis.ContextAdminKey).(*models.Admin); admin != nil {
+		return true, nil
+	}
+	record := authRecord(c)
+	if record == nil {
+		return false, nil
+	}
+
+	return db.IsModerator(roomName, record.Username())
+}
+
+func getModeratorsRoute(app *pocketbase.PocketBase, db store.Store) echo.Route {
+	return echo.Route{
+		Method: http.MethodGet,
+		Path:   "/moderators/*",
+		Handler: func(c echo.Context) error {
+			userNames, err := db.GetModerators(c.PathParam("*"))
+			if err != nil {
+				return err
+			}
+
+			return c.JSON(http.StatusOK, userNames)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.ActivityLogger(app),
+			canonicalRoom(),
+			requireStore(db),
+		},
+	}
+}
+
+func postModeratorRoute(app *pocketbase.PocketBase, db store.Store) echo.Route {
+	return echo.Route{
+		Method: http.MethodPost,
+		Path:   "/moderators/*",
+		Handler: func(c echo.Context) error {
+			userName := c.QueryParam("userName")
+			if userName == "" {
+				return echo.NewHTTPError(http.StatusBadRequest, "missing userName")
+			}
+			if err := db.AddModerator(c.PathParam("*"), userName); err != nil {
+				return err
+			}
+
+			return c.String(http.StatusOK, "OK")
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.ActivityLogger(app),
+			canonicalRoom(),
+			apis.RequireAdminAuth(),
+			requireStore(db),
+		},
+	}
+}
+
+func deleteModeratorRoute(app *pocketbase.PocketBase, db store.Store) echo.Route {
+	return echo.Route{
+		Method: http.MethodDelete,
+		Path:   "/moderators/*",
+		Handler: func(c echo.Context) error {
+			userName := c.QueryParam("userName")
+			if userName == "" {
+				return echo.NewHTTPError(http.StatusBadRequest, "missing userName")
+			}
+			if err := db.RemoveModerator(c.PathParam("*"), userName); err != nil {
+				return err
+			}
+
+			return c.String(http.StatusOK, "OK")
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.ActivityLogger(app),
+			canonicalRoom(),
+			apis.RequireAdminAuth(),
+			requireStore(db),
+		},
+	}
+}
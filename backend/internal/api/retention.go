@@ -0,0 +1,88 @@
+package api
+
+import (
+	"copuchat/internal/store"
+	"net/http"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+)
+
+func getRetentionRoute(app *pocketbase.PocketBase, db store.Store) echo.Route {
+	return echo.Route{
+		Method: http.MethodGet,
+		Path:   "/retention/*",
+		Handler: func(c echo.Context) error {
+			policy, err := db.GetRetention(c.PathParam("*"))
+			if err != nil {
+				return err
+			}
+
+			return c.JSON(http.StatusOK, policy)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.ActivityLogger(app),
+			canonicalRoom(),
+			apis.RequireAdminAuth(),
+			requireStore(db),
+		},
+	}
+}
+
+func putRetentionRoute(app *pocketbase.PocketBase, db store.Store) echo.Route {
+	return echo.Route{
+		Method: http.MethodPut,
+		Path:   "/retention/*",
+		Handler: func(c echo.Context) error {
+			roomName := c.PathParam("*")
+			var retention store.Retention
+			if err := c.Bind(&retention); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "invalid retention")
+			}
+			if err := retention.Validate(); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			if err := db.SetRetention(roomName, &retention); err != nil {
+				return err
+			}
+			policy, err := db.GetRetention(roomName)
+			if err != nil {
+				return err
+			}
+
+			return c.JSON(http.StatusOK, policy)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.ActivityLogger(app),
+			canonicalRoom(),
+			apis.RequireAdminAuth(),
+			requireStore(db),
+		},
+	}
+}
+
+func deleteRetentionRoute(app *pocketbase.PocketBase, db store.Store) echo.Route {
+	return echo.Route{
+		Method: http.MethodDelete,
+		Path:   "/retention/*",
+		Handler: func(c echo.Context) error {
+			roomName := c.PathParam("*")
+			if err := db.SetRetention(roomName, nil); err != nil {
+				return err
+			}
+			policy, err := db.GetRetention(roomName)
+			if err != nil {
+				return err
+			}
+
+			return c.JSON(http.StatusOK, policy)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.ActivityLogger(app),
+			canonicalRoom(),
+			apis.RequireAdminAuth(),
+			requireStore(db),
+		},
+	}
+}
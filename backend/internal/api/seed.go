@@ -0,0 +1,43 @@
+package api
+
+import (
+	"copuchat/internal/seed"
+	"copuchat/internal/store"
+	"io"
+	"net/http"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+)
+
+func postSeedRoute(app *pocketbase.PocketBase, db store.Store) echo.Route {
+	return echo.Route{
+		Method: http.MethodPost,
+		Path:   "/seed",
+		Handler: func(c echo.Context) error {
+			data, err := io.ReadAll(c.Request().Body)
+			if err != nil {
+				return err
+			}
+			file, err := seed.Parse(data)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			if _, err := db.CreateRoom(""); err != nil {
+				return err
+			}
+			result, err := seed.Apply(db, file)
+			if err != nil {
+				return err
+			}
+
+			return c.JSON(http.StatusOK, result)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.ActivityLogger(app),
+			apis.RequireAdminAuth(),
+			requireStore(db),
+		},
+	}
+}
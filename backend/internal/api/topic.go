@@ -0,0 +1,87 @@
+package api
+
+import (
+	"copuchat/internal/store"
+	"net/http"
+
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+)
+
+type topicPolicy struct {
+	Policy string `json:"policy"`
+}
+
+func getTopicPolicyRoute(app *pocketbase.PocketBase, db store.Store) echo.Route {
+	return echo.Route{
+		Method: http.MethodGet,
+		Path:   "/topic_policy/*",
+		Handler: func(c echo.Context) error {
+			policy, err := db.GetTopicPolicy(c.PathParam("*"))
+			if err != nil {
+				return err
+			}
+
+			return c.JSON(http.StatusOK, topicPolicy{Policy: policy})
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.ActivityLogger(app),
+			canonicalRoom(),
+			requireStore(db),
+		},
+	}
+}
+
+func putTopicPolicyRoute(app *pocketbase.PocketBase, db store.Store) echo.Route {
+	return echo.Route{
+		Method: http.MethodPut,
+		Path:   "/topic_policy/*",
+		Handler: func(c echo.Context) error {
+			roomName := c.PathParam("*")
+			var policy topicPolicy
+			if err := c.Bind(&policy); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "invalid topic policy")
+			}
+			if err := store.ValidateTopicPolicy(policy.Policy); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			if err := db.SetTopicPolicy(roomName, policy.Policy); err != nil {
+				return err
+			}
+
+			return c.JSON(http.StatusOK, policy)
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.ActivityLogger(app),
+			canonicalRoom(),
+			apis.RequireAdminAuth(),
+			requireStore(db),
+		},
+	}
+}
+
+func deleteTopicPolicyRoute(app *pocketbase.PocketBase, db store.Store) echo.Route {
+	return echo.Route{
+		Method: http.MethodDelete,
+		Path:   "/topic_policy/*",
+		Handler: func(c echo.Context) error {
+			roomName := c.PathParam("*")
+			if err := db.SetTopicPolicy(roomName, ""); err != nil {
+				return err
+			}
+			policy, err := db.GetTopicPolicy(roomName)
+			if err != nil {
+				return err
+			}
+
+			return c.JSON(http.StatusOK, topicPolicy{Policy: policy})
+		},
+		Middlewares: []echo.MiddlewareFunc{
+			apis.ActivityLogger(app),
+			canonicalRoom(),
+			apis.RequireAdminAuth(),
+			requireStore(db),
+		},
+	}
+}
@@ -0,0 +1,341 @@
+package archive
+
+import (
+	"context"
+	"copuchat/internal/store"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const CollectionName = "messages"
+
+var Interval = 10 * time.Second
+
+// Archive copies the messages of every room from the store into a PocketBase collection, so they outlive the
+// store trimming. Messages are archived as they are added, the sweep catches up with the ones that failed to.
+type Archive struct {
+	app core.App
+	db  store.Store
+
+	mu      sync.Mutex
+	cursors map[string]string // roomName -> last archived message ID.
+}
+
+func New(app core.App, db store.Store) *Archive {
+	return &Archive{app: app, db: db, cursors: map[string]string{}}
+}
+
+func (a *Archive) EnsureCollection() error {
+	if err := a.ensureMessagesCollection(); err != nil {
+		return err
+	}
+	if SearchIndex {
+		return a.ensureTermsCollection()
+	}
+
+	return nil
+}
+
+func (a *Archive) ensureMessagesCollection() error {
+	fields := []*schema.SchemaField{
+		{Name: "room", Type: schema.FieldTypeText},
+		{Name: "messageId", Type: schema.FieldTypeText, Required: true},
+		{Name: "userName", Type: schema.FieldTypeText, Required: true},
+		{Name: "text", Type: schema.FieldTypeText},
+		{Name: "timestamp", Type: schema.FieldTypeNumber, Required: true},
+		{Name: "seq", Type: schema.FieldTypeNumber},
+		{Name: "editedAt", Type: schema.FieldTypeNumber},
+		{Name: "deleted", Type: schema.FieldTypeBool},
+		{Name: "replyTo", Type: schema.FieldTypeText},
+	}
+	indexes := []string{
+		"CREATE UNIQUE INDEX idx_messages_room_message ON messages (room, messageId)",
+		"CREATE INDEX idx_messages_room_order ON messages (room, timestamp, seq)",
+		"CREATE INDEX idx_messages_room_thread ON messages (room, replyTo, timestamp, seq)",
+	}
+
+	collection, err := a.app.Dao().FindCollectionByNameOrId(CollectionName)
+	if err == nil {
+		missing := false
+		for _, field := range fields {
+			if collection.Schema.GetFieldByName(field.Name) == nil {
+				collection.Schema.AddField(field)
+				missing = true
+			}
+		}
+		for _, index := range indexes {
+			if !slices.Contains(collection.Indexes, index) {
+				collection.Indexes = append(collection.Indexes, index)
+				missing = true
+			}
+		}
+		if !missing {
+			return nil
+		}
+	} else {
+		collection = &models.Collection{
+			Name:    CollectionName,
+			Type:    models.CollectionTypeBase,
+			Schema:  schema.NewSchema(fields...),
+			Indexes: types.JsonArray[string](indexes),
+		}
+	}
+	if err := a.app.Dao().SaveCollection(collection); err != nil {
+		return fmt.Errorf("archive: error, could not save %s collection: %w", CollectionName, err)
+	}
+
+	return nil
+}
+
+func (a *Archive) Run(ctx context.Context) {
+	ticker := time.NewTicker(Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if a.db.Health() != nil {
+			continue
+		}
+		if err := a.Sweep(); err != nil {
+			log.Printf("%s\n", err)
+		}
+	}
+}
+
+// Sweep archives the messages added to every room since the last sweep that were not archived as they were
+// added. A room that fails is logged and retried on the next sweep, without holding back the others.
+func (a *Archive) Sweep() error {
+	roomNames, err := a.db.GetRooms()
+	if err != nil {
+		return fmt.Errorf("archive: error getting rooms: %w", err)
+	}
+	for _, roomName := range roomNames {
+		if err := a.archiveRoom(roomName); err != nil {
+			log.Printf("%s\n", err)
+		}
+	}
+
+	return nil
+}
+
+// Add archives a message as it is added, so it is archived before the store can trim it.
+func (a *Archive) Add(roomName string, message store.Message) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.archiveMessages(roomName, []store.Message{message})
+}
+
+// archiveRoom pages forward through the messages of a room from its cursor, archiving a page at a time, so no
+// message is skipped however many were added since the last sweep.
+func (a *Archive) archiveRoom(roomName string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	lastID, ok := a.cursors[roomName]
+	if !ok {
+		var err error
+		if lastID, err = a.lastArchivedID(roomName); err != nil {
+			return err
+		}
+		a.cursors[roomName] = lastID
+	}
+	page := store.Page{After: lastID, Limit: store.MaxPageSize}
+	for {
+		messages, err := a.db.GetMessages(roomName, page)
+		if err != nil {
+			return fmt.Errorf("archive: error getting messages of %s: %w", roomName, err)
+		}
+		if len(messages.Messages) == 0 {
+			return nil
+		}
+		if page.After == lastID {
+			if err := a.checkTrimmed(roomName, lastID, messages.Messages[0].ID); err != nil {
+				return err
+			}
+		}
+		if err := a.archiveMessages(roomName, messages.Messages); err != nil {
+			return err
+		}
+		page.After = messages.Messages[len(messages.Messages)-1].ID
+		a.cursors[roomName] = page.After
+		if !messages.HasMore {
+			return nil
+		}
+	}
+}
+
+// checkTrimmed logs when messages right after lastID were trimmed before being archived, which is the case when
+// lastID is no longer the message right before firstID, the oldest message after it.
+func (a *Archive) checkTrimmed(roomName, lastID, firstID string) error {
+	if lastID == "0-0" {
+		return nil
+	}
+	previous, err := a.db.GetMessages(roomName, store.Page{Before: firstID, Limit: 1})
+	if err != nil {
+		return fmt.Errorf("archive: error getting messages of %s: %w", roomName, err)
+	}
+	if len(previous.Messages) == 0 || previous.Messages[0].ID != lastID {
+		log.Printf("archive: messages of %s after %s were trimmed before being archived\n", roomName, lastID)
+	}
+
+	return nil
+}
+
+func (a *Archive) archiveMessages(roomName string, messages []store.Message) error {
+	collection, err := a.app.Dao().FindCollectionByNameOrId(CollectionName)
+	if err != nil {
+		return fmt.Errorf("archive: error finding %s collection: %w", CollectionName, err)
+	}
+	err = a.app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		for _, message := range messages {
+			if err := saveMessage(txDao, collection, roomName, message); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("archive: error archiving messages of %s: %w", roomName, err)
+	}
+
+	return nil
+}
+
+func saveMessage(dao *daos.Dao, collection *models.Collection, roomName string, message store.Message) error {
+	var exists int
+	err := dao.DB().Select("count(*)").From(collection.Name).
+		Where(dbx.HashExp{"room": roomName, "messageId": message.ID}).
+		Row(&exists)
+	if err != nil {
+		return err
+	}
+	if exists > 0 {
+		return nil
+	}
+	_, seq, err := store.ParseID(message.ID)
+	if err != nil {
+		return err
+	}
+
+	record := models.NewRecord(collection)
+	record.Set("room", roomName)
+	record.Set("messageId", message.ID)
+	record.Set("userName", message.UserName)
+	record.Set("text", message.Text)
+	record.Set("timestamp", message.Timestamp)
+	record.Set("seq", seq)
+	record.Set("editedAt", message.EditedAt)
+	record.Set("deleted", message.Deleted)
+	record.Set("replyTo", message.ReplyTo)
+
+	return dao.SaveRecord(record)
+}
+
+func (a *Archive) lastArchivedID(roomName string) (string, error) {
+	collection, err := a.app.Dao().FindCollectionByNameOrId(CollectionName)
+	if err != nil {
+		return "", fmt.Errorf("archive: error finding %s collection: %w", CollectionName, err)
+	}
+	record := &models.Record{}
+	err = a.app.Dao().RecordQuery(collection).
+		AndWhere(dbx.HashExp{"room": roomName}).
+		OrderBy("timestamp DESC", "seq DESC").
+		Limit(1).
+		One(record)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "0-0", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("archive: error getting last archived message of %s: %w", roomName, err)
+	}
+
+	return record.GetString("messageId"), nil
+}
+
+// GetMessages pages through the archived messages of a room like store.Store.GetMessages does.
+func (a *Archive) GetMessages(roomName string, page store.Page) (store.MessagesPage, error) {
+	return a.getMessages(dbx.HashExp{"room": roomName}, roomName, page)
+}
+
+// GetReplies pages through the archived replies to a message.
+func (a *Archive) GetReplies(roomName, id string, page store.Page) (store.MessagesPage, error) {
+	return a.getMessages(dbx.HashExp{"room": roomName, "replyTo": id}, roomName, page)
+}
+
+func (a *Archive) getMessages(where dbx.Expression, roomName string, page store.Page) (store.MessagesPage, error) {
+	page, err := page.Normalize()
+	if err != nil {
+		return store.MessagesPage{}, err
+	}
+	collection, err := a.app.Dao().FindCollectionByNameOrId(CollectionName)
+	if err != nil {
+		return store.MessagesPage{}, fmt.Errorf("archive: error finding %s collection: %w", CollectionName, err)
+	}
+
+	query := a.app.Dao().RecordQuery(collection).AndWhere(where).Limit(int64(page.Limit + 1))
+	if page.After != "" {
+		query = query.AndWhere(idExp(">", page.After)).OrderBy("timestamp ASC", "seq ASC")
+	} else {
+		if page.Before != "" {
+			query = query.AndWhere(idExp("<", page.Before))
+		}
+		query = query.OrderBy("timestamp DESC", "seq DESC")
+	}
+	records := []*models.Record{}
+	if err := query.All(&records); err != nil {
+		return store.MessagesPage{}, fmt.Errorf("archive: error getting messages of %s: %w", roomName, err)
+	}
+
+	hasMore := len(records) > page.Limit
+	if hasMore {
+		records = records[:page.Limit]
+	}
+	messages := make([]store.Message, len(records))
+	for i, record := range records {
+		messages[i] = recordMessage(record)
+	}
+	if page.After == "" {
+		slices.Reverse(messages)
+	}
+
+	return store.MessagesPage{Messages: messages, HasMore: hasMore}, nil
+}
+
+func recordMessage(record *models.Record) store.Message {
+	return store.Message{
+		ID:        record.GetString("messageId"),
+		UserName:  record.GetString("userName"),
+		Text:      record.GetString("text"),
+		Timestamp: int64(record.GetInt("timestamp")),
+		EditedAt:  int64(record.GetInt("editedAt")),
+		Deleted:   record.GetBool("deleted"),
+		ReplyTo:   record.GetString("replyTo"),
+	}
+}
+
+// idExp compares the (timestamp, seq) order of the archived messages against the given message ID.
+func idExp(op, id string) dbx.Expression {
+	ms, seq, _ := store.ParseID(id)
+
+	return dbx.NewExp(
+		"(timestamp "+op+" {:ms} OR (timestamp = {:ms} AND seq "+op+" {:seq}))",
+		dbx.Params{"ms": ms, "seq": seq},
+	)
+}
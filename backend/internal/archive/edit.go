@@ -0,0 +1,97 @@
+package archive
+
+import (
+	"copuchat/internal/store"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+func (s *Store) GetMessage(roomName, id string) (store.Message, error) {
+	message, err := s.Store.GetMessage(roomName, id)
+	if !errors.Is(err, store.ErrNil) {
+		return message, err
+	}
+	record, archiveErr := s.archive.findRecord(roomName, id)
+	if archiveErr != nil {
+		return store.Message{}, archiveErr
+	}
+	if record == nil {
+		return store.Message{}, err
+	}
+
+	messages := []store.Message{recordMessage(record)}
+	if err := s.decorate(roomName, messages); err != nil {
+		return store.Message{}, err
+	}
+
+	return messages[0], nil
+}
+
+func (s *Store) EditMessage(roomName, id, text, editedBy string) (store.Message, error) {
+	message, err := s.Store.EditMessage(roomName, id, text, editedBy)
+	if err != nil {
+		return message, err
+	}
+
+	return message, s.update(roomName, message)
+}
+
+func (s *Store) DeleteMessage(roomName, id, deletedBy string) (store.Message, error) {
+	message, err := s.Store.DeleteMessage(roomName, id, deletedBy)
+	if err != nil {
+		return message, err
+	}
+
+	return message, s.update(roomName, message)
+}
+
+func (s *Store) update(roomName string, message store.Message) error {
+	if SearchIndex {
+		if err := s.archive.reindex(roomName, message); err != nil {
+			return err
+		}
+	}
+
+	return s.archive.updateMessage(roomName, message)
+}
+
+// updateMessage keeps an already archived message in sync with its latest edit, messages not archived yet
+// are archived with it on the next sweep.
+func (a *Archive) updateMessage(roomName string, message store.Message) error {
+	record, err := a.findRecord(roomName, message.ID)
+	if err != nil || record == nil {
+		return err
+	}
+	record.Set("text", message.Text)
+	record.Set("editedAt", message.EditedAt)
+	record.Set("deleted", message.Deleted)
+	if err := a.app.Dao().SaveRecord(record); err != nil {
+		return fmt.Errorf("archive: error updating message %s of %s: %w", message.ID, roomName, err)
+	}
+
+	return nil
+}
+
+func (a *Archive) findRecord(roomName, id string) (*models.Record, error) {
+	collection, err := a.app.Dao().FindCollectionByNameOrId(CollectionName)
+	if err != nil {
+		return nil, fmt.Errorf("archive: error finding %s collection: %w", CollectionName, err)
+	}
+	record := &models.Record{}
+	err = a.app.Dao().RecordQuery(collection).
+		AndWhere(dbx.HashExp{"room": roomName, "messageId": id}).
+		Limit(1).
+		One(record)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("archive: error getting message %s of %s: %w", id, roomName, err)
+	}
+
+	return record, nil
+}
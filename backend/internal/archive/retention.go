@@ -0,0 +1,41 @@
+package archive
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pocketbase/dbx"
+)
+
+// ApplyRetention also drops the archived messages older than the room retention allows. The archive keeps
+// every message regardless of the retention count, that is what it is for.
+func (s *Store) ApplyRetention(roomName string) error {
+	if err := s.Store.ApplyRetention(roomName); err != nil {
+		return err
+	}
+	policy, err := s.Store.GetRetention(roomName)
+	if err != nil {
+		return err
+	}
+	minID := policy.Effective.MinID(time.Now())
+	if minID == "" {
+		return nil
+	}
+
+	return s.archive.expire(roomName, minID)
+}
+
+func (a *Archive) expire(roomName, minID string) error {
+	where := dbx.And(dbx.HashExp{"room": roomName}, idExp("<", minID))
+	if _, err := a.app.Dao().DB().Delete(CollectionName, where).Execute(); err != nil {
+		return fmt.Errorf("archive: error expiring messages of %s: %w", roomName, err)
+	}
+	if !SearchIndex {
+		return nil
+	}
+	if _, err := a.app.Dao().DB().Delete(TermsCollectionName, where).Execute(); err != nil {
+		return fmt.Errorf("archive: error expiring indexed messages of %s: %w", roomName, err)
+	}
+
+	return nil
+}
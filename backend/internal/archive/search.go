@@ -0,0 +1,184 @@
+package archive
+
+import (
+	"copuchat/internal/store"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const TermsCollectionName = "message_terms"
+
+// SearchIndex makes Store index messages in the PocketBase database as they are added and answer searches from
+// it, covering the whole archived history instead of only what the wrapped store retains.
+var SearchIndex = false
+
+type searchRef struct {
+	Room      string `db:"room"`
+	MessageID string `db:"messageId"`
+}
+
+func (a *Archive) ensureTermsCollection() error {
+	if _, err := a.app.Dao().FindCollectionByNameOrId(TermsCollectionName); err == nil {
+		return nil
+	}
+
+	collection := &models.Collection{
+		Name: TermsCollectionName,
+		Type: models.CollectionTypeBase,
+		Schema: schema.NewSchema(
+			&schema.SchemaField{Name: "room", Type: schema.FieldTypeText},
+			&schema.SchemaField{Name: "messageId", Type: schema.FieldTypeText, Required: true},
+			&schema.SchemaField{Name: "userName", Type: schema.FieldTypeText, Required: true},
+			&schema.SchemaField{Name: "term", Type: schema.FieldTypeText, Required: true},
+			&schema.SchemaField{Name: "timestamp", Type: schema.FieldTypeNumber, Required: true},
+			&schema.SchemaField{Name: "seq", Type: schema.FieldTypeNumber},
+		),
+		Indexes: types.JsonArray[string]{
+			"CREATE INDEX idx_message_terms_term ON message_terms (term, timestamp, seq)",
+			"CREATE INDEX idx_message_terms_message ON message_terms (room, messageId)",
+		},
+	}
+	if err := a.app.Dao().SaveCollection(collection); err != nil {
+		return fmt.Errorf("archive: error, could not create %s collection: %w", TermsCollectionName, err)
+	}
+
+	return nil
+}
+
+func (s *Store) AddMessage(message *store.Message, roomName string, authenticated bool) (bool, error) {
+	newRoom, err := s.Store.AddMessage(message, roomName, authenticated)
+	if err != nil {
+		return newRoom, err
+	}
+	// The message is already added, if archiving it fails the next sweep retries.
+	if err := s.archive.Add(roomName, *message); err != nil {
+		log.Printf("%s\n", err)
+	}
+	if !SearchIndex {
+		return newRoom, nil
+	}
+
+	return newRoom, s.archive.index(roomName, *message)
+}
+
+func (s *Store) Search(query store.SearchQuery) (store.SearchResults, error) {
+	if !SearchIndex {
+		return s.Store.Search(query)
+	}
+	query, err := query.Normalize()
+	if err != nil {
+		return store.SearchResults{}, err
+	}
+	refs, err := s.archive.search(query)
+	if err != nil {
+		return store.SearchResults{}, err
+	}
+
+	results := store.SearchResults{Hits: []store.SearchHit{}, HasMore: len(refs) > query.Limit}
+	if results.HasMore {
+		refs = refs[:query.Limit]
+	}
+	for _, ref := range refs {
+		message, err := s.GetMessage(ref.Room, ref.MessageID)
+		if errors.Is(err, store.ErrNil) {
+			continue
+		}
+		if err != nil {
+			return store.SearchResults{}, err
+		}
+		results.Hits = append(results.Hits, store.SearchHit{RoomName: ref.Room, Message: message})
+	}
+
+	return results, nil
+}
+
+// search returns up to query.Limit+1 messages containing every term in the query scope, newest first.
+func (a *Archive) search(query store.SearchQuery) ([]searchRef, error) {
+	terms := store.SearchTerms(query.Text)
+	values := make([]any, len(terms))
+	for i, term := range terms {
+		values[i] = term
+	}
+
+	q := a.app.Dao().DB().Select("room", "messageId").From(TermsCollectionName).Where(dbx.In("term", values...))
+	if !query.Subtree {
+		q = q.AndWhere(dbx.HashExp{"room": query.Room})
+	} else if query.Room != "" {
+		q = q.AndWhere(dbx.Or(dbx.HashExp{"room": query.Room}, dbx.Like("room", query.Room+"/").Match(false, true)))
+	}
+	if query.Author != "" {
+		q = q.AndWhere(dbx.HashExp{"userName": query.Author})
+	}
+	refs := []searchRef{}
+	err := q.GroupBy("room", "messageId").
+		Having(dbx.NewExp("COUNT(DISTINCT term) = {:terms}", dbx.Params{"terms": len(terms)})).
+		OrderBy("MAX(timestamp) DESC", "MAX(seq) DESC").
+		Offset(int64(query.Offset)).
+		Limit(int64(query.Limit + 1)).
+		All(&refs)
+	if err != nil {
+		return nil, fmt.Errorf("archive: error searching %q: %w", query.Text, err)
+	}
+
+	return refs, nil
+}
+
+func (a *Archive) index(roomName string, message store.Message) error {
+	collection, err := a.app.Dao().FindCollectionByNameOrId(TermsCollectionName)
+	if err != nil {
+		return fmt.Errorf("archive: error finding %s collection: %w", TermsCollectionName, err)
+	}
+	_, seq, err := store.ParseID(message.ID)
+	if err != nil {
+		return err
+	}
+	err = a.app.Dao().RunInTransaction(func(txDao *daos.Dao) error {
+		for _, term := range store.SearchTerms(message.Text) {
+			record := models.NewRecord(collection)
+			record.Set("room", roomName)
+			record.Set("messageId", message.ID)
+			record.Set("userName", message.UserName)
+			record.Set("term", term)
+			record.Set("timestamp", message.Timestamp)
+			record.Set("seq", seq)
+			if err := txDao.SaveRecord(record); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("archive: error indexing message %s of %s: %w", message.ID, roomName, err)
+	}
+
+	return nil
+}
+
+func (a *Archive) unindex(roomName, id string) error {
+	_, err := a.app.Dao().DB().Delete(TermsCollectionName, dbx.HashExp{"room": roomName, "messageId": id}).Execute()
+	if err != nil {
+		return fmt.Errorf("archive: error removing message %s of %s from the index: %w", id, roomName, err)
+	}
+
+	return nil
+}
+
+// reindex replaces the indexed terms of an edited message, deleted messages are only removed.
+func (a *Archive) reindex(roomName string, message store.Message) error {
+	if err := a.unindex(roomName, message.ID); err != nil {
+		return err
+	}
+	if message.Deleted {
+		return nil
+	}
+
+	return a.index(roomName, message)
+}
@@ -0,0 +1,138 @@
+package archive
+
+import (
+	"copuchat/internal/store"
+	"errors"
+	"slices"
+)
+
+// Store reads history from the wrapped store first and falls back to the archive for messages it no longer
+// retains.
+type Store struct {
+	store.Store
+	archive *Archive
+}
+
+func NewStore(db store.Store, archive *Archive) *Store {
+	return &Store{Store: db, archive: archive}
+}
+
+func (s *Store) GetMessages(roomName string, page store.Page) (store.MessagesPage, error) {
+	return s.pageWithArchive(
+		roomName,
+		page,
+		func(page store.Page) (store.MessagesPage, error) { return s.Store.GetMessages(roomName, page) },
+		func(page store.Page) (store.MessagesPage, error) { return s.archive.GetMessages(roomName, page) },
+	)
+}
+
+func (s *Store) GetThread(roomName, id string, page store.Page) (store.Thread, error) {
+	parent, err := s.GetMessage(roomName, id)
+	if err != nil {
+		return store.Thread{}, err
+	}
+	replies, err := s.pageWithArchive(
+		roomName,
+		page,
+		func(page store.Page) (store.MessagesPage, error) {
+			thread, err := s.Store.GetThread(roomName, id, page)
+			if errors.Is(err, store.ErrNil) {
+				return store.MessagesPage{Messages: []store.Message{}}, nil
+			}
+
+			return thread.MessagesPage, err
+		},
+		func(page store.Page) (store.MessagesPage, error) { return s.archive.GetReplies(roomName, id, page) },
+	)
+	if err != nil {
+		return store.Thread{}, err
+	}
+
+	return store.Thread{Parent: parent, MessagesPage: replies}, nil
+}
+
+// pageWithArchive reads a page from the wrapped store, completing it from the archive when the store no longer
+// retains part of it.
+func (s *Store) pageWithArchive(
+	roomName string, page store.Page, live, archived func(store.Page) (store.MessagesPage, error),
+) (store.MessagesPage, error) {
+	page, err := page.Normalize()
+	if err != nil {
+		return store.MessagesPage{}, err
+	}
+	result, err := live(page)
+	if err != nil {
+		return result, err
+	}
+
+	if page.After == "" {
+		if result.HasMore || len(result.Messages) == page.Limit {
+			return result, nil
+		}
+		before := page.Before
+		if len(result.Messages) > 0 {
+			before = result.Messages[0].ID
+		}
+		older, err := archived(store.Page{Before: before, Limit: page.Limit - len(result.Messages)})
+		if err != nil {
+			return result, err
+		}
+		if err := s.decorate(roomName, older.Messages); err != nil {
+			return result, err
+		}
+
+		return store.MessagesPage{Messages: append(older.Messages, result.Messages...), HasMore: older.HasMore}, nil
+	}
+
+	oldest, err := live(store.Page{After: "0-0", Limit: 1})
+	if err != nil {
+		return result, err
+	}
+	if len(oldest.Messages) > 0 && store.CompareIDs(page.After, oldest.Messages[0].ID) >= 0 {
+		return result, nil
+	}
+	older, err := archived(page)
+	if err != nil {
+		return result, err
+	}
+	if err := s.decorate(roomName, older.Messages); err != nil {
+		return result, err
+	}
+
+	return mergePages(older, result, page.Limit), nil
+}
+
+// decorate adds the reactions and reply counts kept by the wrapped store to archived messages.
+func (s *Store) decorate(roomName string, messages []store.Message) error {
+	ids := make([]string, len(messages))
+	for i, message := range messages {
+		ids[i] = message.ID
+	}
+	reactions, err := s.Store.GetReactions(roomName, ids)
+	if err != nil {
+		return err
+	}
+	replies, err := s.Store.GetReplies(roomName, ids)
+	if err != nil {
+		return err
+	}
+	for i := range messages {
+		messages[i].Reactions = reactions[messages[i].ID]
+		messages[i].Replies = replies[messages[i].ID]
+	}
+
+	return nil
+}
+
+// mergePages merges two forward pages of the same range, dropping the messages present in both.
+func mergePages(a, b store.MessagesPage, limit int) store.MessagesPage {
+	messages := append(append([]store.Message{}, a.Messages...), b.Messages...)
+	slices.SortFunc(messages, func(x, y store.Message) int { return store.CompareIDs(x.ID, y.ID) })
+	messages = slices.CompactFunc(messages, func(x, y store.Message) bool { return x.ID == y.ID })
+	hasMore := a.HasMore || b.HasMore || len(messages) > limit
+	if len(messages) > limit {
+		messages = messages[:limit]
+	}
+
+	return store.MessagesPage{Messages: messages, HasMore: hasMore}
+}
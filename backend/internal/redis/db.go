@@ -1,8 +1,10 @@
 package redis
 
 import (
+	"copuchat/internal/store"
 	"errors"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -10,89 +12,199 @@ import (
 	"github.com/gomodule/redigo/redis"
 )
 
-const (
-	hourMinuteTimeLayout = "15:04"
-)
+func chatKey(roomName string) string     { return "chat:" + roomName }
+func topicKey(roomName string) string    { return "topic:" + roomName }
+func subRoomsKey(roomName string) string { return "subs:" + roomName }
 
-var (
-	RoomMaxMessages     = 200
-	TopSubRoomsMaxSize  = 100
-	InactiveUserTimeout = 1 * time.Hour
-	InactiveWindowSize  = InactiveUserTimeout / 2
-)
+// activeUsersKey is a sorted set of the users of a room scored by the unix milliseconds they were last seen.
+func activeUsersKey(roomName string) string { return "chatters:" + roomName }
 
-func chatKey(roomName string) string               { return "chat:" + roomName }
-func topicKey(roomName string) string              { return "topic:" + roomName }
-func subRoomsKey(roomName string) string           { return "subs:" + roomName }
-func activeUserKey(roomName, window string) string { return "chatters:" + roomName + window }
+func (db *DB) GetRooms() ([]string, error) {
+	conn := db.pool.Get()
+	defer conn.Close()
 
-func GetLastMessages(roomName string) ([]Message, error) {
-	conn := pool.Get()
+	roomNames := []string{}
+	cursor := 0
+	for {
+		values, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", chatKey("*"), "TYPE", "stream", "COUNT", 1000))
+		if err != nil {
+			return nil, fmt.Errorf("redis: error, could not scan rooms: %w", err)
+		}
+		keys, _ := redis.Strings(values[1], nil)
+		for _, key := range keys {
+			roomNames = append(roomNames, strings.TrimPrefix(key, chatKey("")))
+		}
+		if cursor, _ = redis.Int(values[0], nil); cursor == 0 {
+			return roomNames, nil
+		}
+	}
+}
+
+func (db *DB) GetLastMessages(roomName string) ([]store.Message, error) {
+	conn := db.pool.Get()
 	defer conn.Close()
 
-	values, err := redis.Values(conn.Do("XREVRANGE", chatKey(roomName), "+", "-", "COUNT", RoomMaxMessages))
+	values, err := redis.Values(conn.Do("XREVRANGE", chatKey(roomName), "+", "-", "COUNT", store.RoomMaxMessages))
 	if err != nil {
 		return nil, fmt.Errorf("redis: error, could not get messages for %s: %w", roomName, err)
 	}
 
-	messages := make([]Message, len(values))
+	messages, err := parseMessages(values)
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(messages)
+
+	return messages, decorateMessages(conn, roomName, messages)
+}
+
+func (db *DB) GetMessagesAfter(roomName, lastID string) ([]store.Message, bool, error) {
+	if _, _, err := store.ParseID(lastID); err != nil {
+		return nil, false, err
+	}
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	values, err := redis.Values(conn.Do("XREVRANGE", chatKey(roomName), "+", "("+lastID, "COUNT", store.RoomMaxMessages+1))
+	if err != nil {
+		return nil, false, fmt.Errorf("redis: error, could not get messages after %s for %s: %w", lastID, roomName, err)
+	}
+	gap := len(values) > store.RoomMaxMessages
+	if gap {
+		values = values[:store.RoomMaxMessages]
+	}
+	messages, err := parseMessages(values)
+	if err != nil {
+		return nil, false, err
+	}
+	slices.Reverse(messages)
+	if err := decorateMessages(conn, roomName, messages); err != nil || gap {
+		return messages, gap, err
+	}
+
+	info, err := redis.Values(conn.Do("XINFO", "STREAM", chatKey(roomName)))
+	if err != nil {
+		var redisErr redis.Error
+		if errors.As(err, &redisErr) && strings.Contains(redisErr.Error(), "no such key") {
+			return messages, false, nil
+		}
+
+		return nil, false, fmt.Errorf("redis: error, could not get stream info for %s: %w", roomName, err)
+	}
+	for i := 0; i+1 < len(info); i += 2 {
+		field, _ := redis.String(info[i], nil)
+		if field != "max-deleted-entry-id" {
+			continue
+		}
+		maxDeletedID, _ := redis.String(info[i+1], nil)
+		gap = store.CompareIDs(maxDeletedID, lastID) > 0
+	}
+
+	return messages, gap, nil
+}
+
+func parseMessages(values []any) ([]store.Message, error) {
+	messages := make([]store.Message, len(values))
 	for i, entryAny := range values {
 		entry, _ := entryAny.([]any)
-		key, _ := entry[0].([]byte)
-		timestamp, err := strconv.ParseInt(strings.Split(string(key), "-")[0], 10, 64)
-		if err != nil {
-			return nil, fmt.Errorf("redis: error, could not parse timestamp %s: %w", key, err)
-		}
+		key, _ := redis.String(entry[0], nil)
 		sm, _ := redis.StringMap(entry[1], nil)
-		messages[len(values)-1-i] = Message{sm["user"], sm["text"], timestamp}
+		message := store.Message{UserName: sm["user"], Text: sm["text"], ReplyTo: sm["replyTo"]}
+		if err := message.SetID(key); err != nil {
+			return nil, fmt.Errorf("redis: error, could not parse message id: %w", err)
+		}
+		messages[i] = message
 	}
 
 	return messages, nil
 }
 
-func AddMessage(message *Message, roomName string) (bool, error) {
-	conn := pool.Get()
-	defer conn.Close()
+// decorateMessages adds what is kept next to the stream to each message: its latest edit, its reactions and
+// its reply count.
+func decorateMessages(conn redis.Conn, roomName string, messages []store.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	if err := applyEdits(conn, roomName, messages); err != nil {
+		return err
+	}
+	ids := make([]string, len(messages))
+	idArgs := make([]any, len(messages))
+	for i, message := range messages {
+		ids[i], idArgs[i] = message.ID, message.ID
+	}
+	reactions, err := getReactions(conn, roomName, ids)
+	if err != nil {
+		return err
+	}
+	replies, err := getReplies(conn, roomName, idArgs)
+	if err != nil {
+		return err
+	}
+	for i := range messages {
+		messages[i].Reactions = reactions[messages[i].ID]
+		messages[i].Replies = replies[i]
+	}
+
+	return nil
+}
+
+func messageFields(message *store.Message) []any {
+	fields := []any{"user", message.UserName, "text", message.Text}
+	if message.ReplyTo != "" {
+		fields = append(fields, "replyTo", message.ReplyTo)
+	}
+
+	return fields
+}
 
-	key, err := redis.String(conn.Do(
-		"XADD", chatKey(roomName), "NOMKSTREAM", "MAXLEN", "~", RoomMaxMessages, "*", "user", message.UserName, "text", message.Text,
-	))
-	newRoom := errors.Is(err, redis.ErrNil)
-	if err != nil && !newRoom {
-		return false, fmt.Errorf("redis: error, could not save message: %w", err)
+func (db *DB) AddMessage(message *store.Message, roomName string, authenticated bool) (bool, error) {
+	if err := store.ValidateRoom(roomName); err != nil {
+		return false, err
 	}
+	if message.ReplyTo != "" {
+		if _, _, err := store.ParseID(message.ReplyTo); err != nil {
+			return false, err
+		}
+	}
+	conn := db.pool.Get()
+	defer conn.Close()
 
-	if newRoom {
-		if err := createRoom(message, roomName); err != nil {
+	retention, err := getRetention(conn, roomName)
+	if err != nil {
+		return false, err
+	}
+	newRoom, err := addMessage(conn, message, roomName, retention, authenticated)
+	if err != nil {
+		return false, err
+	}
+	if err := trimByAge(conn, chatKey(roomName), retention); err != nil {
+		return false, err
+	}
+	if message.ReplyTo != "" {
+		if err := db.addReply(conn, roomName, message, retention); err != nil {
 			return false, err
 		}
-	} else {
-		message.Timestamp, err = strconv.ParseInt(strings.Split(key, "-")[0], 10, 64)
-		if err != nil {
-			return false, fmt.Errorf("redis: error, could not parse given timestamp %s: %w", key, err)
+	}
+	if SearchIndex {
+		if err := indexMessage(conn, roomName, message); err != nil {
+			return false, err
 		}
 	}
 
-	return newRoom, registerUserActivity(roomName, message)
+	return newRoom, db.registerUserActivity(roomName, message)
 }
 
-func GetActiveUsersLen(roomName string) (int, error) {
-	return updateUserActivity(roomName)
+func (db *DB) GetActiveUsersLen(roomName string) (int, error) {
+	return db.updateUserActivity(roomName)
 }
 
-func GetActiveUsers(roomName string) ([]string, error) {
-	conn := pool.Get()
+func (db *DB) GetActiveUsers(roomName string) ([]string, error) {
+	conn := db.pool.Get()
 	defer conn.Close()
 
-	windows := int(InactiveUserTimeout / InactiveWindowSize)
-	keys := make([]any, windows)
-
-	now := time.Now().Truncate(InactiveWindowSize)
-	for i := 0; i < int(InactiveUserTimeout/InactiveWindowSize); i++ {
-		windowKey := now.Add(time.Duration(-i) * InactiveWindowSize).Format("15:04")
-		keys[i] = activeUserKey(roomName, windowKey)
-	}
-	userNames, err := redis.Strings(conn.Do("SUNION", keys...))
+	deadline := activityDeadline()
+	userNames, err := redis.Strings(conn.Do("ZRANGE", activeUsersKey(roomName), "+inf", deadline, "BYSCORE", "REV"))
 	if err != nil {
 		return nil, fmt.Errorf("redis: error, could not get active users on room %s: %w", roomName, err)
 	}
@@ -100,8 +212,8 @@ func GetActiveUsers(roomName string) ([]string, error) {
 	return userNames, nil
 }
 
-func GetTopSubRooms(roomName string) ([]ActiveUsersLen, error) {
-	conn := pool.Get()
+func (db *DB) GetTopSubRooms(roomName string) ([]store.ActiveUsersLen, error) {
+	conn := db.pool.Get()
 	defer conn.Close()
 
 	subRoomNames, err := redis.Strings(conn.Do("ZRANGE", subRoomsKey(roomName), 0, -1)) // TODO: Check all? or some maybe max TopSubRoomsMaxSize?
@@ -113,105 +225,157 @@ func GetTopSubRooms(roomName string) ([]ActiveUsersLen, error) {
 	}
 
 	for _, subRoom := range subRoomNames {
-		if _, err := updateUserActivity(subRoom); err != nil {
+		if _, err := db.updateUserActivity(subRoom); err != nil {
 			return nil, err
 		}
 	}
-	subRoomsStrings, err := redis.Strings(conn.Do("ZRANGE", subRoomsKey(roomName), 0, TopSubRoomsMaxSize, "REV", "WITHSCORES"))
+	subRoomsStrings, err := redis.Strings(conn.Do("ZRANGE", subRoomsKey(roomName), 0, store.TopSubRoomsMaxSize, "REV", "WITHSCORES"))
 	if err != nil {
 		return nil, fmt.Errorf("redis: error, could not get %s top sub rooms: %w", roomName, err)
 	}
 
-	subRooms := make([]ActiveUsersLen, len(subRoomsStrings)/2)
+	subRooms := make([]store.ActiveUsersLen, len(subRoomsStrings)/2)
 	for i := 0; i < len(subRooms); i++ {
 		subRooms[i].RoomName = subRoomsStrings[i*2]
 		subRooms[i].ActiveUsersLen, err = strconv.Atoi(subRoomsStrings[i*2+1])
 		if err != nil {
 			return nil, fmt.Errorf("redis: error, could not parse users len %s: %w", subRoomsStrings[i*2+1], err)
 		}
+		onlineUsers, err := getOnlineUsers(conn, subRooms[i].RoomName)
+		if err != nil {
+			return nil, err
+		}
+		subRooms[i].OnlineUsersLen = len(onlineUsers)
 	}
 
 	return subRooms, nil
 }
 
-func SetTopic(roomName, topic string) error {
-	conn := pool.Get()
+func (db *DB) GetTopic(roomName string) (string, error) {
+	conn := db.pool.Get()
 	defer conn.Close()
 
-	exists, err := redis.Bool(conn.Do("EXISTS", chatKey(roomName)))
+	topic, err := redis.String(conn.Do("GET", topicKey(roomName)))
 	if err != nil {
-		return fmt.Errorf("redis: error, could not check if room %s exists: %w", roomName, err)
-	}
-	if !exists {
-		return fmt.Errorf("redis: error, room %s does not exists can not set topic: %w", roomName, err)
-	}
-	if _, err := conn.Do("SET", topicKey(roomName), topic); err != nil {
-		return fmt.Errorf("redis: error, could not set topic for %s: %w", roomName, err)
+		return "", fmt.Errorf("redis: error, could not get topic for %s: %w", roomName, nilErr(err))
 	}
 
-	return nil
+	return topic, nil
 }
 
-func GetTopic(roomName string) (string, error) {
-	conn := pool.Get()
-	defer conn.Close()
-
-	topic, err := redis.String(conn.Do("GET", topicKey(roomName)))
+// addMessageScript adds a message to a room stream, creating the room under its parent when the stream does not
+// exist yet. Doing it in one script keeps two first messages from both creating the room or from leaving it
+// half created. The author of the message that creates the room is recorded as its owner, unless the owner
+// argument is empty for an anonymous author.
+var addMessageScript = redis.NewScript(4, `
+local id = redis.call('XADD', KEYS[1], 'NOMKSTREAM', 'MAXLEN', '~', ARGV[2], '*', unpack(ARGV, 5))
+if id then
+	return {id, 0}
+end
+if ARGV[3] == '1' then
+	return redis.error_reply('NOTOPLEVEL top-level rooms can not be created by messages')
+end
+if redis.call('EXISTS', KEYS[2]) == 0 then
+	return redis.error_reply('NOPARENT parent room does not exist')
+end
+id = redis.call('XADD', KEYS[1], 'MAXLEN', '~', ARGV[2], '*', unpack(ARGV, 5))
+redis.call('ZADD', KEYS[3], 1, ARGV[1])
+if ARGV[4] ~= '' then
+	redis.call('HSET', KEYS[4], ARGV[1], ARGV[4])
+end
+return {id, 1}
+`)
+
+// createRoomScript creates an empty room stream under its parent, or the root room which has none.
+var createRoomScript = redis.NewScript(3, `
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+if ARGV[1] ~= '' and redis.call('EXISTS', KEYS[2]) == 0 then
+	return redis.error_reply('NOPARENT parent room does not exist')
+end
+redis.call('XGROUP', 'CREATE', KEYS[1], 'create', '$', 'MKSTREAM')
+redis.call('XGROUP', 'DESTROY', KEYS[1], 'create')
+if ARGV[1] ~= '' then
+	redis.call('ZADD', KEYS[3], 0, ARGV[1])
+end
+return 1
+`)
+
+func addMessage(
+	conn redis.Conn, message *store.Message, roomName string, retention store.Retention, authenticated bool,
+) (bool, error) {
+	parentRoom := store.ParentRoom(roomName)
+	forbidCreate := store.IsTopLevel(roomName) && !store.AllowTopLevelRooms
+	owner := ""
+	if authenticated {
+		owner = message.UserName
+	}
+	args := append(
+		[]any{
+			chatKey(roomName), chatKey(parentRoom), subRoomsKey(parentRoom), ownersKey,
+			roomName, retention.MaxMessages, forbidCreate, owner,
+		},
+		messageFields(message)...,
+	)
+	values, err := redis.Values(addMessageScript.Do(conn, args...))
 	if err != nil {
-		return "", fmt.Errorf("redis: error, could not get topic for %s: %w", roomName, err)
+		return false, scriptErr(roomName, err)
+	}
+	key, _ := redis.String(values[0], nil)
+	newRoom, _ := redis.Bool(values[1], nil)
+	if err := message.SetID(key); err != nil {
+		return false, fmt.Errorf("redis: error, could not parse given message id: %w", err)
 	}
 
-	return topic, nil
+	return newRoom, nil
 }
 
-func createRoom(message *Message, roomName string) error {
-	conn := pool.Get()
+func (db *DB) CreateRoom(roomName string) (bool, error) {
+	if err := store.ValidateRoom(roomName); err != nil {
+		return false, err
+	}
+	conn := db.pool.Get()
 	defer conn.Close()
 
-	rooms := strings.Split(roomName, "/")
-	parentRoom := strings.Join(rooms[:len(rooms)-1], "/")
-	exists, err := redis.Bool(conn.Do("EXISTS", chatKey(parentRoom)))
+	parentRoom := store.ParentRoom(roomName)
+	created, err := redis.Bool(createRoomScript.Do(conn, chatKey(roomName), chatKey(parentRoom), subRoomsKey(parentRoom), roomName))
 	if err != nil {
-		return fmt.Errorf("redis: error, could not check if parent of %s exists: %w", roomName, err)
+		return false, scriptErr(roomName, err)
 	}
-	if !exists {
-		return fmt.Errorf("redis: error, parent room does not exists for %s: %w", roomName, err)
-	}
-	key, err := redis.String(conn.Do(
-		"XADD", chatKey(roomName), "MAXLEN", "~", RoomMaxMessages, "*", "user", message.UserName, "text", message.Text,
-	))
-	if err != nil {
-		return fmt.Errorf("redis: error, could not save first message: %w", err)
-	}
-	message.Timestamp, err = strconv.ParseInt(strings.Split(key, "-")[0], 10, 64)
-	if err != nil {
-		return fmt.Errorf("redis: error, could not parse given timestamp from new room %s: %w", key, err)
-	}
-	_, err = conn.Do("ZADD", subRoomsKey(parentRoom), 1, roomName)
-	if err != nil {
-		return fmt.Errorf("redis: error, could not set sub room %s as child: %w", roomName, err)
+
+	return created, nil
+}
+
+func scriptErr(roomName string, err error) error {
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		switch {
+		case strings.HasPrefix(redisErr.Error(), "NOPARENT"):
+			return fmt.Errorf("redis: error, parent room does not exists for %s", roomName)
+		case strings.HasPrefix(redisErr.Error(), "NOTOPLEVEL"):
+			return fmt.Errorf("redis: error, can not create %s: %w", roomName, store.ErrTopLevelRoom)
+		}
 	}
 
-	return nil
+	return fmt.Errorf("redis: error, could not write room %s: %w", roomName, err)
 }
 
-func updateUserActivity(roomName string) (int, error) {
-	conn := pool.Get()
+func (db *DB) updateUserActivity(roomName string) (int, error) {
+	conn := db.pool.Get()
 	defer conn.Close()
 
-	usersLen := 0
-	now := time.Now().Truncate(InactiveWindowSize)
-	for i := 0; i < int(InactiveUserTimeout/InactiveWindowSize); i++ {
-		windowKey := now.Add(time.Duration(-i) * InactiveWindowSize).Format("15:04")
-		windowLen, err := redis.Int(conn.Do("SCARD", activeUserKey(roomName, windowKey)))
-		if err != nil {
-			return 0, fmt.Errorf("redis: error, could not get active users length: %w", err)
-		}
-		usersLen += windowLen
+	key := activeUsersKey(roomName)
+	deadline := activityDeadline()
+	if _, err := conn.Do("ZREMRANGEBYSCORE", key, "-inf", fmt.Sprintf("(%d", deadline)); err != nil {
+		return 0, fmt.Errorf("redis: error, could not expire inactive users on %s: %w", key, err)
+	}
+	usersLen, err := redis.Int(conn.Do("ZCARD", key))
+	if err != nil {
+		return 0, fmt.Errorf("redis: error, could not get active users length: %w", err)
 	}
 
-	rooms := strings.Split(roomName, "/")
-	parentRoom := strings.Join(rooms[:len(rooms)-1], "/")
+	parentRoom := store.ParentRoom(roomName)
 
 	if roomName != parentRoom {
 		_, err := conn.Do("ZADD", subRoomsKey(parentRoom), usersLen, roomName)
@@ -223,22 +387,26 @@ func updateUserActivity(roomName string) (int, error) {
 	return usersLen, nil
 }
 
-func registerUserActivity(roomName string, message *Message) error {
-	conn := pool.Get()
+func (db *DB) registerUserActivity(roomName string, message *store.Message) error {
+	conn := db.pool.Get()
 	defer conn.Close()
 
-	windowKey := time.UnixMilli(message.Timestamp).Truncate(InactiveWindowSize).Format(hourMinuteTimeLayout)
-	key := activeUserKey(roomName, windowKey)
-
-	_, err := conn.Do("SADD", activeUserKey(roomName, windowKey), message.UserName)
+	key := activeUsersKey(roomName)
+	_, err := conn.Do("ZADD", key, "GT", message.Timestamp, message.UserName)
 	if err != nil {
-		return fmt.Errorf("redis: error, could not add member to set %s: %w", key, err)
+		return fmt.Errorf("redis: error, could not add member to sorted set %s: %w", key, err)
 	}
 
-	_, err = conn.Do("PEXPIRE", key, InactiveWindowSize.Milliseconds(), "NX")
+	// The whole set is idle once its latest member is, the inactive members are dropped when it is read.
+	_, err = conn.Do("PEXPIRE", key, store.InactiveUserTimeout.Milliseconds())
 	if err != nil {
 		return fmt.Errorf("redis: error, could not set expiration on %s: %w", key, err)
 	}
 
 	return nil
 }
+
+// activityDeadline is the unix milliseconds users must have been last seen at, or after, to be active.
+func activityDeadline() int64 {
+	return time.Now().Add(-store.InactiveUserTimeout).UnixMilli()
+}
@@ -0,0 +1,159 @@
+package redis
+
+import (
+	"context"
+	"copuchat/internal/store"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// connectTest connects to the Redis at REDIS_URL, or the default one, skipping the test when it is unavailable.
+func connectTest(t *testing.T) *DB {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	config := DefaultConfig()
+	config.ConnectRetries = 0
+	if url := os.Getenv("REDIS_URL"); url != "" {
+		config.URL = url
+	}
+	db, err := Connect(ctx, config)
+	if err != nil {
+		t.Skipf("redis unavailable: %s", err)
+	}
+
+	return db
+}
+
+// testRoom returns a new room name right below the root room, removing everything stored about it and its sub
+// rooms once the test is done.
+func testRoom(t *testing.T, db *DB) string {
+	t.Helper()
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	roomName := "test-" + hex.EncodeToString(b)
+	createdRoot, err := db.CreateRoom("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn := db.pool.Get()
+		defer conn.Close()
+
+		keys, err := redis.Strings(conn.Do("KEYS", "*"+roomName+"*"))
+		if err != nil {
+			t.Error(err)
+
+			return
+		}
+		owned, err := redis.Strings(conn.Do("HKEYS", ownersKey))
+		if err != nil {
+			t.Error(err)
+
+			return
+		}
+		commands := []command{newCommand("ZREM", subRoomsKey(""), roomName)}
+		for _, key := range keys {
+			commands = append(commands, newCommand("DEL", key))
+		}
+		for _, owned := range owned {
+			if owned == roomName || strings.HasPrefix(owned, roomName+"/") {
+				commands = append(commands, newCommand("HDEL", ownersKey, owned))
+			}
+		}
+		if createdRoot {
+			commands = append(commands, newCommand("DEL", chatKey("")))
+		}
+		if _, err := pipeline(conn, commands...); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return roomName
+}
+
+func TestConcurrentFirstMessages(t *testing.T) {
+	const messages = 20
+	db := connectTest(t)
+	// The messages are not searched, keep them out of the shared index.
+	SearchIndex = false
+	t.Cleanup(func() { SearchIndex = true })
+	parent := testRoom(t, db)
+	if _, err := db.CreateRoom(parent); err != nil {
+		t.Fatal(err)
+	}
+	roomName := parent + "/new"
+
+	newRooms := make([]bool, messages)
+	var wg sync.WaitGroup
+	for i := range newRooms {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			message := &store.Message{UserName: fmt.Sprintf("user%d", i), Text: fmt.Sprintf("first %d", i)}
+			newRoom, err := db.AddMessage(message, roomName, true)
+			if err != nil {
+				t.Error(err)
+			}
+			newRooms[i] = newRoom
+		}()
+	}
+	wg.Wait()
+
+	created := 0
+	for _, newRoom := range newRooms {
+		if newRoom {
+			created++
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d messages created the room, want 1", created)
+	}
+
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	subRooms, err := redis.Strings(conn.Do("ZRANGE", subRoomsKey(parent), 0, -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subRooms) != 1 || subRooms[0] != roomName {
+		t.Errorf("sub rooms of %s = %v, want [%s]", parent, subRooms, roomName)
+	}
+	length, err := redis.Int(conn.Do("XLEN", chatKey(roomName)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if length != messages {
+		t.Errorf("room stream has %d messages, want %d", length, messages)
+	}
+}
+
+func TestAnonymousFirstMessageHasNoOwner(t *testing.T) {
+	db := connectTest(t)
+	SearchIndex = false
+	t.Cleanup(func() { SearchIndex = true })
+	roomName := testRoom(t, db)
+
+	newRoom, err := db.AddMessage(&store.Message{UserName: "alice", Text: "hello"}, roomName, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !newRoom {
+		t.Fatalf("first message did not create %s", roomName)
+	}
+	if owner, err := db.GetRoomOwner(roomName); !errors.Is(err, store.ErrNil) {
+		t.Errorf("room created anonymously is owned by %q, %v", owner, err)
+	}
+}
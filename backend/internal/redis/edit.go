@@ -0,0 +1,188 @@
+package redis
+
+import (
+	"copuchat/internal/store"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+func editsKey(roomName string) string           { return "edits:" + roomName }
+func editHistoryKey(roomName, id string) string { return "edit_history:" + roomName + ":" + id }
+func moderatorsKey(roomName string) string      { return "mods:" + roomName }
+
+func (db *DB) GetMessage(roomName, id string) (store.Message, error) {
+	if _, _, err := store.ParseID(id); err != nil {
+		return store.Message{}, err
+	}
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	return db.getMessage(conn, roomName, id)
+}
+
+func (db *DB) EditMessage(roomName, id, text, editedBy string) (store.Message, error) {
+	return db.changeMessage(roomName, id, store.MessageEdit{Text: text, EditedBy: editedBy})
+}
+
+func (db *DB) DeleteMessage(roomName, id, deletedBy string) (store.Message, error) {
+	return db.changeMessage(roomName, id, store.MessageEdit{EditedBy: deletedBy, Deleted: true})
+}
+
+func (db *DB) GetMessageEdits(roomName, id string) ([]store.MessageEdit, error) {
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	values, err := redis.ByteSlices(conn.Do("LRANGE", editHistoryKey(roomName, id), 0, -1))
+	if err != nil {
+		return nil, fmt.Errorf("redis: error, could not get edit history of %s in %s: %w", id, roomName, err)
+	}
+	edits := make([]store.MessageEdit, len(values))
+	for i, value := range values {
+		if err := json.Unmarshal(value, &edits[i]); err != nil {
+			return nil, fmt.Errorf("redis: error, could not parse edit of %s: %w", id, err)
+		}
+	}
+
+	return edits, nil
+}
+
+func (db *DB) IsModerator(roomName, userName string) (bool, error) {
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	for {
+		isModerator, err := redis.Bool(conn.Do("SISMEMBER", moderatorsKey(roomName), userName))
+		if err != nil {
+			return false, fmt.Errorf("redis: error, could not check moderators of %s: %w", roomName, err)
+		}
+		if isModerator || roomName == "" {
+			return isModerator, nil
+		}
+		roomName = store.ParentRoom(roomName)
+	}
+}
+
+func (db *DB) GetModerators(roomName string) ([]string, error) {
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	userNames, err := redis.Strings(conn.Do("SMEMBERS", moderatorsKey(roomName)))
+	if err != nil {
+		return nil, fmt.Errorf("redis: error, could not get moderators of %s: %w", roomName, err)
+	}
+
+	return userNames, nil
+}
+
+func (db *DB) AddModerator(roomName, userName string) error {
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("SADD", moderatorsKey(roomName), userName); err != nil {
+		return fmt.Errorf("redis: error, could not add moderator to %s: %w", roomName, err)
+	}
+
+	return nil
+}
+
+func (db *DB) RemoveModerator(roomName, userName string) error {
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("SREM", moderatorsKey(roomName), userName); err != nil {
+		return fmt.Errorf("redis: error, could not remove moderator from %s: %w", roomName, err)
+	}
+
+	return nil
+}
+
+func (db *DB) changeMessage(roomName, id string, edit store.MessageEdit) (store.Message, error) {
+	if _, _, err := store.ParseID(id); err != nil {
+		return store.Message{}, err
+	}
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	message, err := db.getMessage(conn, roomName, id)
+	if err != nil {
+		return store.Message{}, err
+	}
+	if message.Deleted {
+		return store.Message{}, fmt.Errorf("redis: error, message %s was deleted", id)
+	}
+	edit.EditedAt = time.Now().UnixMilli()
+	data, err := json.Marshal(edit)
+	if err != nil {
+		return store.Message{}, fmt.Errorf("redis: error, could not encode edit: %w", err)
+	}
+
+	_, err = transaction(conn,
+		newCommand("HSET", editsKey(roomName), id, data),
+		newCommand("RPUSH", editHistoryKey(roomName, id), data),
+	)
+	if err != nil {
+		return store.Message{}, fmt.Errorf("redis: error, could not save edit of %s in %s: %w", id, roomName, err)
+	}
+	message.Apply(edit)
+	if err := unindexMessage(conn, roomName, id); err != nil {
+		return message, err
+	}
+	if SearchIndex && !message.Deleted {
+		if err := indexMessage(conn, roomName, &message); err != nil {
+			return message, err
+		}
+	}
+
+	return message, nil
+}
+
+func (db *DB) getMessage(conn redis.Conn, roomName, id string) (store.Message, error) {
+	values, err := redis.Values(conn.Do("XRANGE", chatKey(roomName), id, id))
+	if err != nil {
+		return store.Message{}, fmt.Errorf("redis: error, could not get message %s in %s: %w", id, roomName, err)
+	}
+	if len(values) == 0 {
+		return store.Message{}, fmt.Errorf("redis: error, message %s not found in %s: %w", id, roomName, store.ErrNil)
+	}
+	messages, err := parseMessages(values)
+	if err != nil {
+		return store.Message{}, err
+	}
+	if err := decorateMessages(conn, roomName, messages); err != nil {
+		return store.Message{}, err
+	}
+
+	return messages[0], nil
+}
+
+// applyEdits overlays the latest edit of each message, edits are kept next to the stream since stream entries
+// can not be changed in place.
+func applyEdits(conn redis.Conn, roomName string, messages []store.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	args := make([]any, len(messages)+1)
+	args[0] = editsKey(roomName)
+	for i, message := range messages {
+		args[i+1] = message.ID
+	}
+	values, err := redis.ByteSlices(conn.Do("HMGET", args...))
+	if err != nil {
+		return fmt.Errorf("redis: error, could not get edits for %s: %w", roomName, err)
+	}
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		var edit store.MessageEdit
+		if err := json.Unmarshal(value, &edit); err != nil {
+			return fmt.Errorf("redis: error, could not parse edit of %s: %w", messages[i].ID, err)
+		}
+		messages[i].Apply(edit)
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package redis
+
+import (
+	"copuchat/internal/store"
+	"fmt"
+	"slices"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+func (db *DB) GetMessages(roomName string, page store.Page) (store.MessagesPage, error) {
+	page, err := page.Normalize()
+	if err != nil {
+		return store.MessagesPage{}, err
+	}
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	return getPage(conn, roomName, chatKey(roomName), page)
+}
+
+// getPage reads a normalized page from the stream at key, which holds messages of roomName.
+func getPage(conn redis.Conn, roomName, key string, page store.Page) (store.MessagesPage, error) {
+	var values []any
+	var err error
+	if page.After != "" {
+		values, err = redis.Values(conn.Do("XRANGE", key, "("+page.After, "+", "COUNT", page.Limit+1))
+	} else {
+		end := "+"
+		if page.Before != "" {
+			end = "(" + page.Before
+		}
+		values, err = redis.Values(conn.Do("XREVRANGE", key, end, "-", "COUNT", page.Limit+1))
+	}
+	if err != nil {
+		return store.MessagesPage{}, fmt.Errorf("redis: error, could not get messages page for %s: %w", roomName, err)
+	}
+
+	hasMore := len(values) > page.Limit
+	if hasMore {
+		values = values[:page.Limit]
+	}
+	messages, err := parseMessages(values)
+	if err != nil {
+		return store.MessagesPage{}, err
+	}
+	if page.After == "" {
+		slices.Reverse(messages)
+	}
+	if err := decorateMessages(conn, roomName, messages); err != nil {
+		return store.MessagesPage{}, err
+	}
+
+	return store.MessagesPage{Messages: messages, HasMore: hasMore}, nil
+}
@@ -0,0 +1,107 @@
+package redis
+
+import (
+	"copuchat/internal/store"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// onlineKey is a sorted set of the sessions of a room, as sessionID:userName, scored by the unix milliseconds
+// they were last refreshed at. presentKey is the set of users announced as joined.
+func onlineKey(roomName string) string  { return "online:" + roomName }
+func presentKey(roomName string) string { return "present:" + roomName }
+
+// addSessionScript refreshes a session and marks its user as present, returning 1 when they were not yet.
+var addSessionScript = redis.NewScript(2, `
+redis.call('ZADD', KEYS[1], ARGV[3], ARGV[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+local joined = redis.call('SADD', KEYS[2], ARGV[2])
+redis.call('PEXPIRE', KEYS[2], ARGV[4])
+return joined
+`)
+
+// leaveRoomScript removes a user from the present ones when none of their sessions is online, returning 1 when
+// they were present.
+var leaveRoomScript = redis.NewScript(2, `
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', '(' .. ARGV[2])
+for _, member in ipairs(redis.call('ZRANGE', KEYS[1], 0, -1)) do
+	if string.sub(member, string.find(member, ':', 1, true) + 1) == ARGV[1] then
+		return 0
+	end
+end
+return redis.call('SREM', KEYS[2], ARGV[1])
+`)
+
+func (db *DB) AddSession(roomName, userName, sessionID string) (bool, error) {
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	joined, err := redis.Bool(addSessionScript.Do(
+		conn, onlineKey(roomName), presentKey(roomName),
+		sessionMember(userName, sessionID), userName, time.Now().UnixMilli(), store.OnlineTimeout.Milliseconds(),
+	))
+	if err != nil {
+		return false, fmt.Errorf("redis: error, could not add session of %s to room %s: %w", userName, roomName, err)
+	}
+
+	return joined, nil
+}
+
+func (db *DB) RemoveSession(roomName, userName, sessionID string) error {
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("ZREM", onlineKey(roomName), sessionMember(userName, sessionID)); err != nil {
+		return fmt.Errorf("redis: error, could not remove session of %s from room %s: %w", userName, roomName, err)
+	}
+
+	return nil
+}
+
+func (db *DB) LeaveRoom(roomName, userName string) (bool, error) {
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	left, err := redis.Bool(leaveRoomScript.Do(conn, onlineKey(roomName), presentKey(roomName), userName, onlineDeadline()))
+	if err != nil {
+		return false, fmt.Errorf("redis: error, could not remove %s from room %s: %w", userName, roomName, err)
+	}
+
+	return left, nil
+}
+
+func (db *DB) GetOnlineUsers(roomName string) ([]string, error) {
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	return getOnlineUsers(conn, roomName)
+}
+
+func getOnlineUsers(conn redis.Conn, roomName string) ([]string, error) {
+	members, err := redis.Strings(conn.Do("ZRANGE", onlineKey(roomName), "+inf", onlineDeadline(), "BYSCORE", "REV"))
+	if err != nil {
+		return nil, fmt.Errorf("redis: error, could not get online users on room %s: %w", roomName, err)
+	}
+	seen := map[string]bool{}
+	userNames := []string{}
+	for _, member := range members {
+		_, userName, _ := strings.Cut(member, ":")
+		if !seen[userName] {
+			seen[userName] = true
+			userNames = append(userNames, userName)
+		}
+	}
+	sort.Strings(userNames)
+
+	return userNames, nil
+}
+
+func sessionMember(userName, sessionID string) string { return sessionID + ":" + userName }
+
+func onlineDeadline() int64 {
+	return time.Now().Add(-store.OnlineTimeout).UnixMilli()
+}
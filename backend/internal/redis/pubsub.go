@@ -0,0 +1,178 @@
+package redis
+
+import (
+	"copuchat/internal/store"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	pubSubPingInterval  = 30 * time.Second
+	pubSubBufferSize    = 256
+	pubSubHeartbeatChan = "events-heartbeat"
+)
+
+func eventsKey(roomName string) string { return "events:" + roomName }
+
+// pubSub multiplexes every room subscription of this instance over a single Redis connection, reconnecting
+// and resubscribing when it drops.
+type pubSub struct {
+	db      *DB
+	mu      sync.Mutex
+	conn    *redis.PubSubConn
+	subs    map[string]map[*subscription]struct{}
+	started bool
+}
+
+type subscription struct {
+	ps       *pubSub
+	channel  string
+	messages chan []byte
+	closed   bool
+}
+
+func (s *subscription) Messages() <-chan []byte { return s.messages }
+
+func (s *subscription) Close() error {
+	s.ps.mu.Lock()
+	defer s.ps.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	close(s.messages)
+	delete(s.ps.subs[s.channel], s)
+	if len(s.ps.subs[s.channel]) > 0 {
+		return nil
+	}
+	delete(s.ps.subs, s.channel)
+	if s.ps.conn != nil {
+		if err := s.ps.conn.Unsubscribe(s.channel); err != nil {
+			return fmt.Errorf("redis: error, could not unsubscribe from %s: %w", s.channel, err)
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) Publish(roomName string, payload []byte) error {
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("PUBLISH", eventsKey(roomName), payload); err != nil {
+		return fmt.Errorf("redis: error, could not publish to %s: %w", roomName, err)
+	}
+
+	return nil
+}
+
+func (db *DB) Subscribe(roomName string) (store.Subscription, error) {
+	ps := db.pubSub
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	channel := eventsKey(roomName)
+	sub := &subscription{ps: ps, channel: channel, messages: make(chan []byte, pubSubBufferSize)}
+	if ps.subs[channel] == nil {
+		ps.subs[channel] = map[*subscription]struct{}{}
+		if ps.conn != nil {
+			if err := ps.conn.Subscribe(channel); err != nil {
+				delete(ps.subs, channel)
+
+				return nil, fmt.Errorf("redis: error, could not subscribe to %s: %w", roomName, err)
+			}
+		}
+	}
+	ps.subs[channel][sub] = struct{}{}
+	if !ps.started {
+		ps.started = true
+		go ps.run()
+	}
+
+	return sub, nil
+}
+
+func (ps *pubSub) run() {
+	backoff := ps.db.config.MinBackoff
+	for {
+		connected, err := ps.receive()
+		if connected {
+			backoff = ps.db.config.MinBackoff
+		}
+		log.Printf("redis: pubsub connection lost, reconnecting in %s: %s\n", backoff, err)
+		time.Sleep(backoff)
+		backoff = nextBackoff(backoff, ps.db.config)
+	}
+}
+
+func (ps *pubSub) receive() (bool, error) {
+	conn := &redis.PubSubConn{Conn: ps.db.pool.Get()}
+	defer conn.Close()
+
+	ps.mu.Lock()
+	channels := []any{pubSubHeartbeatChan}
+	for channel := range ps.subs {
+		channels = append(channels, channel)
+	}
+	if err := conn.Subscribe(channels...); err != nil {
+		ps.mu.Unlock()
+
+		return false, err
+	}
+	ps.conn = conn
+	ps.mu.Unlock()
+
+	done := make(chan struct{})
+	defer func() {
+		close(done)
+		ps.mu.Lock()
+		ps.conn = nil
+		ps.mu.Unlock()
+	}()
+	go ps.ping(conn, done)
+
+	for {
+		switch v := conn.ReceiveWithTimeout(2 * pubSubPingInterval).(type) {
+		case redis.Message:
+			ps.dispatch(v.Channel, v.Data)
+		case error:
+			return true, v
+		}
+	}
+}
+
+func (ps *pubSub) ping(conn *redis.PubSubConn, done chan struct{}) {
+	ticker := time.NewTicker(pubSubPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			ps.mu.Lock()
+			err := conn.Ping("")
+			ps.mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (ps *pubSub) dispatch(channel string, payload []byte) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	for sub := range ps.subs[channel] {
+		select {
+		case sub.messages <- payload:
+		default:
+			log.Printf("redis: pubsub subscriber of %s is full, dropping event\n", channel)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package redis
+
+import (
+	"copuchat/internal/store"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+func reactionsKey(roomName string) string { return "reactions:" + roomName }
+
+// reactScript adds or removes a user from the reactions of a message, kept as a JSON object of emoji ->
+// userNames in a hash next to the room stream. Emojis and messages left without reactions are removed.
+var reactScript = redis.NewScript(1, `
+local raw = redis.call('HGET', KEYS[1], ARGV[1])
+local reactions = raw and cjson.decode(raw) or {}
+local users = reactions[ARGV[2]] or {}
+local index = nil
+for i, user in ipairs(users) do
+	if user == ARGV[3] then index = i end
+end
+if ARGV[4] == 'add' and index == nil then table.insert(users, ARGV[3]) end
+if ARGV[4] == 'remove' and index ~= nil then table.remove(users, index) end
+if #users == 0 then reactions[ARGV[2]] = nil else reactions[ARGV[2]] = users end
+if next(reactions) == nil then
+	redis.call('HDEL', KEYS[1], ARGV[1])
+	return false
+end
+raw = cjson.encode(reactions)
+redis.call('HSET', KEYS[1], ARGV[1], raw)
+return raw
+`)
+
+func (db *DB) AddReaction(roomName, id, emoji, userName string) ([]store.Reaction, error) {
+	if err := store.ValidateReaction(emoji); err != nil {
+		return nil, err
+	}
+
+	return db.react(roomName, id, emoji, userName, "add")
+}
+
+func (db *DB) RemoveReaction(roomName, id, emoji, userName string) ([]store.Reaction, error) {
+	return db.react(roomName, id, emoji, userName, "remove")
+}
+
+func (db *DB) GetReactions(roomName string, ids []string) (map[string][]store.Reaction, error) {
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	return getReactions(conn, roomName, ids)
+}
+
+func (db *DB) react(roomName, id, emoji, userName, action string) ([]store.Reaction, error) {
+	if _, _, err := store.ParseID(id); err != nil {
+		return nil, err
+	}
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	raw, err := redis.Bytes(reactScript.Do(conn, reactionsKey(roomName), id, emoji, userName, action))
+	if errors.Is(err, redis.ErrNil) {
+		return []store.Reaction{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis: error, could not %s reaction on %s in %s: %w", action, id, roomName, err)
+	}
+
+	return parseReactions(raw)
+}
+
+func getReactions(conn redis.Conn, roomName string, ids []string) (map[string][]store.Reaction, error) {
+	reactions := map[string][]store.Reaction{}
+	if len(ids) == 0 {
+		return reactions, nil
+	}
+	args := make([]any, len(ids)+1)
+	args[0] = reactionsKey(roomName)
+	for i, id := range ids {
+		args[i+1] = id
+	}
+	values, err := redis.ByteSlices(conn.Do("HMGET", args...))
+	if err != nil {
+		return nil, fmt.Errorf("redis: error, could not get reactions for %s: %w", roomName, err)
+	}
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		if reactions[ids[i]], err = parseReactions(value); err != nil {
+			return nil, err
+		}
+	}
+
+	return reactions, nil
+}
+
+func parseReactions(raw []byte) ([]store.Reaction, error) {
+	var userNames map[string][]string
+	if err := json.Unmarshal(raw, &userNames); err != nil {
+		return nil, fmt.Errorf("redis: error, could not parse reactions: %w", err)
+	}
+
+	return store.NewReactions(userNames), nil
+}
@@ -1,9 +1,12 @@
 package redis
 
 import (
+	"context"
+	"copuchat/internal/store"
+	"errors"
 	"fmt"
 	"log"
-	"os"
+	"sync"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
@@ -13,54 +16,234 @@ const (
 	DefaultURL  = "localhost:6379"
 	idleTimeout = 5 * time.Minute
 	maxIdle     = 10
+	dialTimeout = 5 * time.Second
 )
 
-var (
-	ErrNil              = redis.ErrNil
-	TestOnBorrowTimeout = 1 * time.Minute
-	pool                *redis.Pool
-)
+var TestOnBorrowTimeout = 1 * time.Minute
+
+type Config struct {
+	URL                 string
+	ConnectRetries      int
+	MinBackoff          time.Duration
+	MaxBackoff          time.Duration
+	HealthCheckInterval time.Duration
+}
 
-func init() {
-	url := os.Getenv("REDIS_URL")
-	if url == "" {
-		url = fmt.Sprintf("redis://%s", DefaultURL)
-		log.Println("REDIS_URL env var not set, using localhost")
+func DefaultConfig() Config {
+	return Config{
+		URL:                 fmt.Sprintf("redis://%s", DefaultURL),
+		ConnectRetries:      5,
+		MinBackoff:          250 * time.Millisecond,
+		MaxBackoff:          30 * time.Second,
+		HealthCheckInterval: 10 * time.Second,
 	}
+}
 
-	pool = &redis.Pool{
-		MaxIdle:     maxIdle,
-		IdleTimeout: idleTimeout,
-		Dial:        func() (redis.Conn, error) { return redis.DialURL(url, redis.DialTLSSkipVerify(true)) },
-		TestOnBorrow: func(c redis.Conn, t time.Time) error {
-			if time.Since(t) < TestOnBorrowTimeout {
-				return nil
-			}
-			_, err := c.Do("PING")
+type DB struct {
+	pool   *redis.Pool
+	config Config
 
-			return err
+	healthMu  sync.RWMutex
+	healthErr error
+
+	pubSub *pubSub
+}
+
+var _ store.Store = (*DB)(nil)
+
+// Connect dials Redis retrying with exponential backoff. If every retry fails the returned DB is still usable
+// in degraded mode together with the last error: Health reports store.ErrUnavailable and a background
+// monitor keeps reconnecting until ctx is done.
+func Connect(ctx context.Context, config Config) (*DB, error) {
+	if config.URL == "" {
+		config.URL = DefaultConfig().URL
+	}
+	url := config.URL
+	db := &DB{
+		config: config,
+		pool: &redis.Pool{
+			MaxIdle:     maxIdle,
+			IdleTimeout: idleTimeout,
+			Dial: func() (redis.Conn, error) {
+				return redis.DialURL(url, redis.DialTLSSkipVerify(true), redis.DialConnectTimeout(dialTimeout))
+			},
+			TestOnBorrow: func(c redis.Conn, t time.Time) error {
+				if time.Since(t) < TestOnBorrowTimeout {
+					return nil
+				}
+				_, err := c.Do("PING")
+
+				return err
+			},
 		},
 	}
 
-	conn := pool.Get()
-	defer conn.Close()
-	if _, err := conn.Do("PING"); err != nil {
-		log.Panicf("redis: could not connect: %s", err)
+	db.pubSub = &pubSub{db: db, subs: map[string]map[*subscription]struct{}{}}
+
+	backoff := config.MinBackoff
+	err := db.ping(ctx)
+	for attempt := 0; err != nil && ctx.Err() == nil && attempt < config.ConnectRetries; attempt++ {
+		log.Printf("redis: could not connect, retrying in %s: %s\n", backoff, err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+			err = db.ping(ctx)
+		}
+		backoff = nextBackoff(backoff, config)
+	}
+	db.setHealth(err)
+	go db.monitor(ctx)
+
+	if err != nil {
+		return db, fmt.Errorf("redis: could not connect: %w", err)
+	}
+
+	return db, nil
+}
+
+func (db *DB) Health() error {
+	db.healthMu.RLock()
+	defer db.healthMu.RUnlock()
+
+	if db.healthErr != nil {
+		return fmt.Errorf("%w: %w", store.ErrUnavailable, db.healthErr)
+	}
+
+	return nil
+}
+
+func (db *DB) Close() error {
+	return db.pool.Close()
+}
+
+func (db *DB) monitor(ctx context.Context) {
+	interval := db.config.HealthCheckInterval
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+
+		wasHealthy := db.Health() == nil
+		err := db.ping(ctx)
+		db.setHealth(err)
+		switch {
+		case err != nil && wasHealthy:
+			log.Printf("redis: connection lost, chat unavailable: %s\n", err)
+		case err == nil && !wasHealthy:
+			log.Println("redis: connection restored")
+		}
+		switch {
+		case err == nil:
+			interval = db.config.HealthCheckInterval
+		case wasHealthy:
+			interval = db.config.MinBackoff
+		default:
+			interval = nextBackoff(interval, db.config)
+		}
+	}
+}
+
+func (db *DB) ping(ctx context.Context) error {
+	conn, err := db.pool.GetContext(ctx)
+	if err != nil {
+		return err
 	}
+	defer conn.Close()
+	_, err = conn.Do("PING")
+
+	return err
+}
+
+func (db *DB) setHealth(err error) {
+	db.healthMu.Lock()
+	defer db.healthMu.Unlock()
+
+	db.healthErr = err
 }
 
-func Get(key string) ([]byte, error) {
-	conn := pool.Get()
+func nextBackoff(backoff time.Duration, config Config) time.Duration {
+	return min(backoff*2, config.MaxBackoff)
+}
+
+func (db *DB) GetCache(key string) ([]byte, error) {
+	conn := db.pool.Get()
 	defer conn.Close()
 
-	return redis.Bytes(conn.Do("GET", key))
+	data, err := redis.Bytes(conn.Do("GET", key))
+
+	return data, nilErr(err)
 }
 
-func SetPX(key string, data []byte, expiration time.Duration) error {
-	conn := pool.Get()
+func (db *DB) SetCache(key string, data []byte, expiration time.Duration) error {
+	conn := db.pool.Get()
 	defer conn.Close()
 
 	_, err := conn.Do("SET", key, data, "PX", expiration.Milliseconds())
 
 	return err
 }
+
+// nilErr translates redigo's nil reply into store.ErrNil so callers don't depend on redigo.
+func nilErr(err error) error {
+	if errors.Is(err, redis.ErrNil) {
+		return fmt.Errorf("%w: %w", store.ErrNil, err)
+	}
+
+	return err
+}
+
+// command is a Redis command queued by pipeline or transaction.
+type command struct {
+	name string
+	args []any
+}
+
+func newCommand(name string, args ...any) command {
+	return command{name: name, args: args}
+}
+
+// pipeline sends the commands in a single round trip and returns their replies. Redigo returns the error reply of
+// a pipelined command as a value rather than an error, so every reply is checked.
+func pipeline(conn redis.Conn, commands ...command) ([]any, error) {
+	for _, c := range commands {
+		if err := conn.Send(c.name, c.args...); err != nil {
+			return nil, err
+		}
+	}
+	replies, err := redis.Values(conn.Do(""))
+	if err != nil {
+		return nil, err
+	}
+
+	return replies, replyErr(replies)
+}
+
+// transaction runs the commands in a MULTI/EXEC block and returns their replies, checked like pipeline does.
+func transaction(conn redis.Conn, commands ...command) ([]any, error) {
+	if err := conn.Send("MULTI"); err != nil {
+		return nil, err
+	}
+	for _, c := range commands {
+		if err := conn.Send(c.name, c.args...); err != nil {
+			return nil, err
+		}
+	}
+	replies, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return nil, err
+	}
+
+	return replies, replyErr(replies)
+}
+
+func replyErr(replies []any) error {
+	for _, reply := range replies {
+		if err, ok := reply.(redis.Error); ok {
+			return err
+		}
+	}
+
+	return nil
+}
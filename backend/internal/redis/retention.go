@@ -0,0 +1,211 @@
+package redis
+
+import (
+	"copuchat/internal/store"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const retentionKey = "retention"
+
+func (db *DB) GetRetention(roomName string) (store.RetentionPolicy, error) {
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	overrides, err := getRetentionOverrides(conn, roomName)
+	if err != nil {
+		return store.RetentionPolicy{}, err
+	}
+
+	return store.RetentionPolicy{Override: overrides[0], Effective: store.InheritRetention(overrides)}, nil
+}
+
+func (db *DB) SetRetention(roomName string, retention *store.Retention) error {
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	if retention == nil {
+		if _, err := conn.Do("HDEL", retentionKey, roomName); err != nil {
+			return fmt.Errorf("redis: error, could not remove retention of %s: %w", roomName, err)
+		}
+
+		return nil
+	}
+	if err := retention.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(retention)
+	if err != nil {
+		return fmt.Errorf("redis: error, could not encode retention: %w", err)
+	}
+	if _, err := conn.Do("HSET", retentionKey, roomName, data); err != nil {
+		return fmt.Errorf("redis: error, could not set retention of %s: %w", roomName, err)
+	}
+
+	return nil
+}
+
+// ApplyRetention trims the room stream and its thread streams exactly, while adding a message only trims them
+// approximately. The data kept next to the streams about the trimmed messages is pruned with them, unless
+// store.PruneTrimmed is off.
+func (db *DB) ApplyRetention(roomName string) error {
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	retention, err := getRetention(conn, roomName)
+	if err != nil {
+		return err
+	}
+	threads, err := redis.Strings(conn.Do("HKEYS", repliesKey(roomName)))
+	if err != nil {
+		return fmt.Errorf("redis: error, could not get threads of %s: %w", roomName, err)
+	}
+	keys := []string{chatKey(roomName)}
+	for _, id := range threads {
+		keys = append(keys, threadKey(roomName, id))
+	}
+	minID := retention.MinID(time.Now())
+	commands := []command{}
+	for _, key := range keys {
+		commands = append(commands, newCommand("XTRIM", key, "MAXLEN", retention.MaxMessages))
+		if minID != "" {
+			commands = append(commands, newCommand("XTRIM", key, "MINID", minID))
+		}
+	}
+	if _, err := pipeline(conn, commands...); err != nil {
+		return fmt.Errorf("redis: error, could not apply retention to %s: %w", roomName, err)
+	}
+	if store.PruneTrimmed {
+		if err := pruneTrimmed(conn, roomName, threads); err != nil {
+			return err
+		}
+	}
+
+	return pruneIndex(conn, roomName)
+}
+
+// pruneTrimmed drops the threads, edits and reactions of the messages trimmed from the stream of roomName. Replies
+// are newer than their thread parent, so the threads kept only hold messages the stream still retains.
+func pruneTrimmed(conn redis.Conn, roomName string, threads []string) error {
+	oldestID, err := oldestMessageID(conn, chatKey(roomName))
+	if err != nil {
+		return err
+	}
+	commands := []command{}
+	for _, id := range threads {
+		if isTrimmed(id, oldestID) {
+			commands = append(commands, newCommand("DEL", threadKey(roomName, id)), newCommand("HDEL", repliesKey(roomName), id))
+		}
+	}
+	edited, err := trimmedFields(conn, editsKey(roomName), oldestID)
+	if err != nil {
+		return err
+	}
+	for _, id := range edited {
+		commands = append(commands, newCommand("HDEL", editsKey(roomName), id), newCommand("DEL", editHistoryKey(roomName, id)))
+	}
+	reacted, err := trimmedFields(conn, reactionsKey(roomName), oldestID)
+	if err != nil {
+		return err
+	}
+	for _, id := range reacted {
+		commands = append(commands, newCommand("HDEL", reactionsKey(roomName), id))
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+	if _, err := pipeline(conn, commands...); err != nil {
+		return fmt.Errorf("redis: error, could not prune trimmed messages of %s: %w", roomName, err)
+	}
+
+	return nil
+}
+
+// trimmedFields returns the message IDs of the hash at key older than oldestID.
+func trimmedFields(conn redis.Conn, key, oldestID string) ([]string, error) {
+	ids, err := redis.Strings(conn.Do("HKEYS", key))
+	if err != nil {
+		return nil, fmt.Errorf("redis: error, could not get the messages in %s: %w", key, err)
+	}
+	trimmed := []string{}
+	for _, id := range ids {
+		if isTrimmed(id, oldestID) {
+			trimmed = append(trimmed, id)
+		}
+	}
+
+	return trimmed, nil
+}
+
+// isTrimmed reports whether id is older than oldestID, the oldest retained message, which is empty when none is.
+func isTrimmed(id, oldestID string) bool {
+	return oldestID == "" || store.CompareIDs(id, oldestID) < 0
+}
+
+// oldestMessageID returns the ID of the oldest message in the stream at key, empty when it has none.
+func oldestMessageID(conn redis.Conn, key string) (string, error) {
+	values, err := redis.Values(conn.Do("XRANGE", key, "-", "+", "COUNT", 1))
+	if err != nil {
+		return "", fmt.Errorf("redis: error, could not get the oldest message of %s: %w", key, err)
+	}
+	if len(values) == 0 {
+		return "", nil
+	}
+	messages, err := parseMessages(values)
+	if err != nil {
+		return "", err
+	}
+
+	return messages[0].ID, nil
+}
+
+func getRetention(conn redis.Conn, roomName string) (store.Retention, error) {
+	overrides, err := getRetentionOverrides(conn, roomName)
+	if err != nil {
+		return store.Retention{}, err
+	}
+
+	return store.InheritRetention(overrides), nil
+}
+
+// getRetentionOverrides returns the retention set by the room and by every room above it, nil where unset.
+func getRetentionOverrides(conn redis.Conn, roomName string) ([]*store.Retention, error) {
+	prefixes := store.RoomPrefixes(roomName)
+	args := make([]any, len(prefixes)+1)
+	args[0] = retentionKey
+	for i, prefix := range prefixes {
+		args[i+1] = prefix
+	}
+	values, err := redis.ByteSlices(conn.Do("HMGET", args...))
+	if err != nil {
+		return nil, fmt.Errorf("redis: error, could not get retention of %s: %w", roomName, err)
+	}
+	overrides := make([]*store.Retention, len(values))
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		overrides[i] = &store.Retention{}
+		if err := json.Unmarshal(value, overrides[i]); err != nil {
+			return nil, fmt.Errorf("redis: error, could not parse retention of %s: %w", prefixes[i], err)
+		}
+	}
+
+	return overrides, nil
+}
+
+// trimByAge approximately drops the messages at key older than the retention allows.
+func trimByAge(conn redis.Conn, key string, retention store.Retention) error {
+	minID := retention.MinID(time.Now())
+	if minID == "" {
+		return nil
+	}
+	if _, err := conn.Do("XTRIM", key, "MINID", "~", minID); err != nil {
+		return fmt.Errorf("redis: error, could not trim %s by age: %w", key, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,192 @@
+package redis
+
+import (
+	"copuchat/internal/store"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// The search index is a sorted set per term, room, room subtree and author, holding searchMember of every
+// message scored by its timestamp. A search intersects the sets of its terms and scope. Only the messages still
+// in the room streams are found, the ones trimmed since they were indexed are dropped from it as they show up and
+// when the retention is applied.
+func searchTermKey(term string) string        { return "search:term:" + term }
+func searchRoomKey(roomName string) string    { return "search:room:" + roomName }
+func searchTreeKey(roomName string) string    { return "search:tree:" + roomName }
+func searchUserKey(userName string) string    { return "search:user:" + userName }
+func searchDocKey(roomName, id string) string { return "search:doc:" + searchMember(roomName, id) }
+func searchMember(roomName, id string) string { return id + ":" + roomName }
+
+// SearchIndex makes AddMessage index messages for Search, it is turned off when the archive answers searches.
+var SearchIndex = true
+
+func (db *DB) Search(query store.SearchQuery) (store.SearchResults, error) {
+	query, err := query.Normalize()
+	if err != nil {
+		return store.SearchResults{}, err
+	}
+	keys := []any{}
+	for _, term := range store.SearchTerms(query.Text) {
+		keys = append(keys, searchTermKey(term))
+	}
+	if !query.Subtree {
+		keys = append(keys, searchRoomKey(query.Room))
+	} else if query.Room != "" {
+		keys = append(keys, searchTreeKey(query.Room))
+	}
+	if query.Author != "" {
+		keys = append(keys, searchUserKey(query.Author))
+	}
+	weights := make([]any, len(keys))
+	weights[0] = 1
+	for i := 1; i < len(weights); i++ {
+		weights[i] = 0
+	}
+	tmp, err := tempKey()
+	if err != nil {
+		return store.SearchResults{}, err
+	}
+
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	args := append(append(append([]any{tmp, len(keys)}, keys...), "WEIGHTS"), weights...)
+	values, err := transaction(conn,
+		newCommand("ZINTERSTORE", args...),
+		newCommand("ZREVRANGE", tmp, query.Offset, query.Offset+query.Limit),
+		newCommand("DEL", tmp),
+	)
+	if err != nil {
+		return store.SearchResults{}, fmt.Errorf("redis: error, could not search %q: %w", query.Text, err)
+	}
+	members, err := redis.Strings(values[1], nil)
+	if err != nil {
+		return store.SearchResults{}, fmt.Errorf("redis: error, could not read search results: %w", err)
+	}
+
+	results := store.SearchResults{Hits: []store.SearchHit{}, HasMore: len(members) > query.Limit}
+	if results.HasMore {
+		members = members[:query.Limit]
+	}
+	for _, member := range members {
+		id, roomName, _ := strings.Cut(member, ":")
+		message, err := db.getMessage(conn, roomName, id)
+		if errors.Is(err, store.ErrNil) {
+			if err := unindexMessage(conn, roomName, id); err != nil {
+				return store.SearchResults{}, err
+			}
+
+			continue
+		}
+		if err != nil {
+			return store.SearchResults{}, err
+		}
+		results.Hits = append(results.Hits, store.SearchHit{RoomName: roomName, Message: message})
+	}
+
+	return results, nil
+}
+
+func indexMessage(conn redis.Conn, roomName string, message *store.Message) error {
+	member := searchMember(roomName, message.ID)
+	terms := store.SearchTerms(message.Text)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	commands := []command{}
+	for _, term := range terms {
+		commands = append(commands, newCommand("ZADD", searchTermKey(term), message.Timestamp, member))
+	}
+	commands = append(commands,
+		newCommand("HSET", searchDocKey(roomName, message.ID), "user", message.UserName, "terms", strings.Join(terms, " ")),
+		newCommand("ZADD", searchRoomKey(roomName), message.Timestamp, member),
+	)
+	for _, prefix := range store.RoomPrefixes(roomName) {
+		if prefix != "" {
+			commands = append(commands, newCommand("ZADD", searchTreeKey(prefix), message.Timestamp, member))
+		}
+	}
+	commands = append(commands, newCommand("ZADD", searchUserKey(message.UserName), message.Timestamp, member))
+	if _, err := transaction(conn, commands...); err != nil {
+		return fmt.Errorf("redis: error, could not index message %s: %w", message.ID, err)
+	}
+
+	return nil
+}
+
+// unindexMessage removes a message from the index, using the author and terms recorded when it was indexed.
+func unindexMessage(conn redis.Conn, roomName, id string) error {
+	member := searchMember(roomName, id)
+	doc, err := redis.StringMap(conn.Do("HGETALL", searchDocKey(roomName, id)))
+	if err != nil {
+		return fmt.Errorf("redis: error, could not get indexed terms of %s: %w", id, err)
+	}
+	if len(doc) == 0 {
+		return nil
+	}
+
+	commands := []command{}
+	for _, term := range strings.Fields(doc["terms"]) {
+		commands = append(commands, newCommand("ZREM", searchTermKey(term), member))
+	}
+	commands = append(commands,
+		newCommand("DEL", searchDocKey(roomName, id)),
+		newCommand("ZREM", searchRoomKey(roomName), member),
+	)
+	for _, prefix := range store.RoomPrefixes(roomName) {
+		if prefix != "" {
+			commands = append(commands, newCommand("ZREM", searchTreeKey(prefix), member))
+		}
+	}
+	commands = append(commands, newCommand("ZREM", searchUserKey(doc["user"]), member))
+	if _, err := transaction(conn, commands...); err != nil {
+		return fmt.Errorf("redis: error, could not remove message %s from the index: %w", id, err)
+	}
+
+	return nil
+}
+
+// pruneIndex removes the messages of roomName trimmed from its stream from the index. They are scored by their
+// timestamp, which is the time part of their ID, so only the ones up to the oldest retained message are checked.
+func pruneIndex(conn redis.Conn, roomName string) error {
+	oldestID, err := oldestMessageID(conn, chatKey(roomName))
+	if err != nil {
+		return err
+	}
+	maxScore := "+inf"
+	if oldestID != "" {
+		ms, _, _ := store.ParseID(oldestID)
+		maxScore = strconv.FormatInt(ms, 10)
+	}
+	members, err := redis.Strings(conn.Do("ZRANGE", searchRoomKey(roomName), "-inf", maxScore, "BYSCORE"))
+	if err != nil {
+		return fmt.Errorf("redis: error, could not get indexed messages of %s: %w", roomName, err)
+	}
+	for _, member := range members {
+		id, _, _ := strings.Cut(member, ":")
+		if !isTrimmed(id, oldestID) {
+			continue
+		}
+		if err := unindexMessage(conn, roomName, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func tempKey() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("redis: error, could not generate temporary key: %w", err)
+	}
+
+	return "tmp:" + hex.EncodeToString(b), nil
+}
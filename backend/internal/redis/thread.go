@@ -0,0 +1,84 @@
+package redis
+
+import (
+	"copuchat/internal/store"
+	"fmt"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+func threadKey(roomName, id string) string { return "thread:" + roomName + ":" + id }
+func repliesKey(roomName string) string    { return "replies:" + roomName }
+
+func (db *DB) GetThread(roomName, id string, page store.Page) (store.Thread, error) {
+	page, err := page.Normalize()
+	if err != nil {
+		return store.Thread{}, err
+	}
+	if _, _, err := store.ParseID(id); err != nil {
+		return store.Thread{}, err
+	}
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	parent, err := db.getMessage(conn, roomName, id)
+	if err != nil {
+		return store.Thread{}, err
+	}
+	replies, err := getPage(conn, roomName, threadKey(roomName, id), page)
+	if err != nil {
+		return store.Thread{}, err
+	}
+
+	return store.Thread{Parent: parent, MessagesPage: replies}, nil
+}
+
+// addReply copies an already added reply into its thread stream under the same ID, so the thread outlives the
+// trimming of the room stream.
+func (db *DB) addReply(conn redis.Conn, roomName string, message *store.Message, retention store.Retention) error {
+	key := threadKey(roomName, message.ReplyTo)
+	args := append([]any{key, "MAXLEN", "~", retention.MaxMessages, message.ID}, messageFields(message)...)
+	_, err := transaction(conn,
+		newCommand("XADD", args...),
+		newCommand("HINCRBY", repliesKey(roomName), message.ReplyTo, 1),
+	)
+	if err != nil {
+		return fmt.Errorf("redis: error, could not add reply to thread %s: %w", message.ReplyTo, err)
+	}
+
+	return trimByAge(conn, key, retention)
+}
+
+func (db *DB) GetReplies(roomName string, ids []string) (map[string]int, error) {
+	replies := map[string]int{}
+	if len(ids) == 0 {
+		return replies, nil
+	}
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	idArgs := make([]any, len(ids))
+	for i, id := range ids {
+		idArgs[i] = id
+	}
+	counts, err := getReplies(conn, roomName, idArgs)
+	if err != nil {
+		return nil, err
+	}
+	for i, id := range ids {
+		if counts[i] > 0 {
+			replies[id] = counts[i]
+		}
+	}
+
+	return replies, nil
+}
+
+func getReplies(conn redis.Conn, roomName string, ids []any) ([]int, error) {
+	replies, err := redis.Ints(conn.Do("HMGET", append([]any{repliesKey(roomName)}, ids...)...))
+	if err != nil {
+		return nil, fmt.Errorf("redis: error, could not get replies for %s: %w", roomName, err)
+	}
+
+	return replies, nil
+}
@@ -0,0 +1,131 @@
+package redis
+
+import (
+	"copuchat/internal/store"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	// ownersKey is a hash of roomName -> the user whose message created the room.
+	ownersKey = "owners"
+	// topicPoliciesKey is a hash of roomName -> topic policy, for the rooms that have one.
+	topicPoliciesKey = "topic_policies"
+)
+
+// topicHistoryKey is a list of the JSON encoded topic changes of a room, newest first.
+func topicHistoryKey(roomName string) string { return "topic_history:" + roomName }
+
+// setTopicScript sets the topic of an existing room and pushes the change, with the topic it replaced, to the
+// room topic history.
+var setTopicScript = redis.NewScript(3, `
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return redis.error_reply('NOROOM room does not exist')
+end
+local previous = redis.call('GET', KEYS[2]) or ''
+redis.call('SET', KEYS[2], ARGV[1])
+local change = cjson.encode({topic = ARGV[1], previous = previous, setBy = ARGV[2], setAt = tonumber(ARGV[3])})
+redis.call('LPUSH', KEYS[3], change)
+redis.call('LTRIM', KEYS[3], 0, tonumber(ARGV[4]) - 1)
+return change
+`)
+
+func (db *DB) SetTopic(roomName, topic, setBy string) (store.TopicChange, error) {
+	if err := store.ValidateRoom(roomName); err != nil {
+		return store.TopicChange{}, err
+	}
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	data, err := redis.Bytes(setTopicScript.Do(
+		conn, chatKey(roomName), topicKey(roomName), topicHistoryKey(roomName),
+		topic, setBy, time.Now().UnixMilli(), store.TopicHistoryMaxSize,
+	))
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) && strings.HasPrefix(redisErr.Error(), "NOROOM") {
+		return store.TopicChange{}, fmt.Errorf("redis: error, room %s does not exists can not set topic", roomName)
+	}
+	if err != nil {
+		return store.TopicChange{}, fmt.Errorf("redis: error, could not set topic for %s: %w", roomName, err)
+	}
+	var change store.TopicChange
+	if err := json.Unmarshal(data, &change); err != nil {
+		return store.TopicChange{}, fmt.Errorf("redis: error, could not parse topic change of %s: %w", roomName, err)
+	}
+
+	return change, nil
+}
+
+func (db *DB) GetTopicHistory(roomName string, limit int) ([]store.TopicChange, error) {
+	if limit <= 0 {
+		return []store.TopicChange{}, nil
+	}
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	values, err := redis.ByteSlices(conn.Do("LRANGE", topicHistoryKey(roomName), 0, limit-1))
+	if err != nil {
+		return nil, fmt.Errorf("redis: error, could not get topic history of %s: %w", roomName, err)
+	}
+	history := make([]store.TopicChange, len(values))
+	for i, data := range values {
+		if err := json.Unmarshal(data, &history[i]); err != nil {
+			return nil, fmt.Errorf("redis: error, could not parse topic change of %s: %w", roomName, err)
+		}
+	}
+
+	return history, nil
+}
+
+func (db *DB) GetTopicPolicy(roomName string) (string, error) {
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	policy, err := redis.String(conn.Do("HGET", topicPoliciesKey, roomName))
+	if errors.Is(err, redis.ErrNil) {
+		return store.DefaultTopicPolicy, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("redis: error, could not get topic policy of %s: %w", roomName, err)
+	}
+
+	return policy, nil
+}
+
+func (db *DB) SetTopicPolicy(roomName, policy string) error {
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	if policy == "" {
+		if _, err := conn.Do("HDEL", topicPoliciesKey, roomName); err != nil {
+			return fmt.Errorf("redis: error, could not remove topic policy of %s: %w", roomName, err)
+		}
+
+		return nil
+	}
+	if err := store.ValidateTopicPolicy(policy); err != nil {
+		return err
+	}
+	if _, err := conn.Do("HSET", topicPoliciesKey, roomName, policy); err != nil {
+		return fmt.Errorf("redis: error, could not set topic policy of %s: %w", roomName, err)
+	}
+
+	return nil
+}
+
+func (db *DB) GetRoomOwner(roomName string) (string, error) {
+	conn := db.pool.Get()
+	defer conn.Close()
+
+	owner, err := redis.String(conn.Do("HGET", ownersKey, roomName))
+	if err != nil {
+		return "", fmt.Errorf("redis: error, could not get owner of %s: %w", roomName, nilErr(err))
+	}
+
+	return owner, nil
+}
@@ -0,0 +1,45 @@
+package retention
+
+import (
+	"context"
+	"copuchat/internal/store"
+	"fmt"
+	"log"
+	"time"
+)
+
+var Interval = 1 * time.Minute
+
+// Run applies the retention of every room periodically, so rooms that stopped receiving messages are trimmed
+// too.
+func Run(ctx context.Context, db store.Store) {
+	ticker := time.NewTicker(Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if db.Health() != nil {
+			continue
+		}
+		if err := Sweep(db); err != nil {
+			log.Printf("%s\n", err)
+		}
+	}
+}
+
+func Sweep(db store.Store) error {
+	roomNames, err := db.GetRooms()
+	if err != nil {
+		return fmt.Errorf("retention: error getting rooms: %w", err)
+	}
+	for _, roomName := range roomNames {
+		if err := db.ApplyRetention(roomName); err != nil {
+			return fmt.Errorf("retention: error applying retention to %s: %w", roomName, err)
+		}
+	}
+
+	return nil
+}
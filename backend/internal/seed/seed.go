@@ -0,0 +1,163 @@
+package seed
+
+import (
+	"context"
+	"copuchat/internal/store"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var BootstrapInterval = 5 * time.Second
+
+// Room is a room to seed, with the settings to apply to it. Its missing parent rooms are created too.
+type Room struct {
+	Name        string           `json:"name"`
+	Topic       string           `json:"topic,omitempty"`
+	TopicPolicy string           `json:"topicPolicy,omitempty"`
+	Retention   *store.Retention `json:"retention,omitempty"`
+	Moderators  []string         `json:"moderators,omitempty"`
+}
+
+type File struct {
+	Rooms []Room `json:"rooms"`
+}
+
+type Result struct {
+	Created []string `json:"created"`
+	Existed []string `json:"existed"`
+}
+
+// Parse reads a seed file in YAML or JSON, JSON being valid YAML.
+func Parse(data []byte) (File, error) {
+	var raw any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return File{}, fmt.Errorf("seed: error parsing file: %w", err)
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return File{}, fmt.Errorf("seed: error parsing file: %w", err)
+	}
+	var file File
+	if err := json.Unmarshal(data, &file); err != nil {
+		return File{}, fmt.Errorf("seed: error parsing file: %w", err)
+	}
+	for i, room := range file.Rooms {
+		roomName, err := store.CanonicalRoom(room.Name)
+		if err != nil {
+			return File{}, fmt.Errorf("seed: error in room %s: %w", room.Name, err)
+		}
+		file.Rooms[i].Name = roomName
+		if room.TopicPolicy != "" {
+			if err := store.ValidateTopicPolicy(room.TopicPolicy); err != nil {
+				return File{}, fmt.Errorf("seed: error in room %s: %w", room.Name, err)
+			}
+		}
+		if room.Retention != nil {
+			if err := room.Retention.Validate(); err != nil {
+				return File{}, fmt.Errorf("seed: error in room %s: %w", room.Name, err)
+			}
+		}
+	}
+
+	return file, nil
+}
+
+func Load(path string) (File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return File{}, fmt.Errorf("seed: error reading %s: %w", path, err)
+	}
+
+	return Parse(data)
+}
+
+// Apply creates the rooms of file, root room included, and applies their settings. It can be applied again,
+// existing rooms only get their settings updated.
+func Apply(db store.Store, file File) (Result, error) {
+	result := Result{Created: []string{}, Existed: []string{}}
+	for _, room := range file.Rooms {
+		prefixes := store.RoomPrefixes(room.Name)
+		slices.Reverse(prefixes)
+		for _, roomName := range prefixes {
+			created, err := db.CreateRoom(roomName)
+			if err != nil {
+				return result, fmt.Errorf("seed: error creating room %s: %w", roomName, err)
+			}
+			if created {
+				result.Created = append(result.Created, roomName)
+			} else if roomName == room.Name {
+				result.Existed = append(result.Existed, roomName)
+			}
+		}
+		if room.Topic != "" {
+			if err := setTopic(db, room.Name, room.Topic); err != nil {
+				return result, err
+			}
+		}
+		if room.TopicPolicy != "" {
+			if err := db.SetTopicPolicy(room.Name, room.TopicPolicy); err != nil {
+				return result, fmt.Errorf("seed: error setting topic policy of %s: %w", room.Name, err)
+			}
+		}
+		if room.Retention != nil {
+			if err := db.SetRetention(room.Name, room.Retention); err != nil {
+				return result, fmt.Errorf("seed: error setting retention of %s: %w", room.Name, err)
+			}
+		}
+		for _, userName := range room.Moderators {
+			if err := db.AddModerator(room.Name, userName); err != nil {
+				return result, fmt.Errorf("seed: error adding moderator to %s: %w", room.Name, err)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Bootstrap creates the root room once the store is available, so a fresh deployment has a room to post into.
+func Bootstrap(ctx context.Context, db store.Store) {
+	ticker := time.NewTicker(BootstrapInterval)
+	defer ticker.Stop()
+	for {
+		if db.Health() == nil {
+			created, err := db.CreateRoom("")
+			if err == nil {
+				if created {
+					log.Println("seed: created root room")
+				}
+
+				return
+			}
+			log.Printf("seed: error creating root room: %s\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// setTopic sets the topic of a seeded room unless it is already set, so applying a file again does not fill the
+// topic history.
+func setTopic(db store.Store, roomName, topic string) error {
+	current, err := db.GetTopic(roomName)
+	if err != nil && !errors.Is(err, store.ErrNil) {
+		return fmt.Errorf("seed: error getting topic of %s: %w", roomName, err)
+	}
+	if current == topic {
+		return nil
+	}
+	if _, err := db.SetTopic(roomName, topic, ""); err != nil {
+		return fmt.Errorf("seed: error setting topic of %s: %w", roomName, err)
+	}
+
+	return nil
+}
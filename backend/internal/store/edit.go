@@ -0,0 +1,14 @@
+package store
+
+// MessageEdit is a change made to a message after it was sent, the latest one is applied when reading the
+// message and all of them are kept as its edit history.
+type MessageEdit struct {
+	Text     string `json:"text"`
+	EditedBy string `json:"editedBy"`
+	EditedAt int64  `json:"editedAt"`
+	Deleted  bool   `json:"deleted,omitempty"`
+}
+
+func (m *Message) Apply(edit MessageEdit) {
+	m.Text, m.EditedAt, m.Deleted = edit.Text, edit.EditedAt, edit.Deleted
+}
@@ -0,0 +1,43 @@
+package store
+
+import "errors"
+
+var (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+// Page selects a page of a room history. After pages forward from that message ID, otherwise the page ends
+// right before Before, or at the newest message when Before is empty. Both bounds are exclusive so the first or
+// last ID of a page can be passed back as the cursor for the next one.
+type Page struct {
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+}
+
+// MessagesPage holds messages in chronological order, HasMore reports there are more in the paging direction.
+type MessagesPage struct {
+	Messages []Message `json:"messages"`
+	HasMore  bool      `json:"hasMore"`
+}
+
+func (p Page) Normalize() (Page, error) {
+	if p.Before != "" && p.After != "" {
+		return p, errors.New("store: error, page can not be both before and after a message")
+	}
+	for _, id := range []string{p.Before, p.After} {
+		if id == "" {
+			continue
+		}
+		if _, _, err := ParseID(id); err != nil {
+			return p, err
+		}
+	}
+	if p.Limit <= 0 {
+		p.Limit = DefaultPageSize
+	}
+	p.Limit = min(p.Limit, MaxPageSize)
+
+	return p, nil
+}
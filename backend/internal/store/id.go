@@ -0,0 +1,37 @@
+package store
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseID splits a stream ID of the form <milliseconds>-<sequence>, a missing sequence is read as 0.
+func ParseID(id string) (int64, int64, error) {
+	msPart, seqPart, hasSeq := strings.Cut(id, "-")
+	ms, err := strconv.ParseInt(msPart, 10, 64)
+	if err != nil || ms < 0 {
+		return 0, 0, fmt.Errorf("store: error, invalid message id %q", id)
+	}
+	if !hasSeq {
+		return ms, 0, nil
+	}
+	seq, err := strconv.ParseInt(seqPart, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, 0, fmt.Errorf("store: error, invalid message id %q", id)
+	}
+
+	return ms, seq, nil
+}
+
+// CompareIDs returns -1, 0 or 1 when a is older, the same or newer than b. Invalid IDs sort first.
+func CompareIDs(a, b string) int {
+	aMs, aSeq, _ := ParseID(a)
+	bMs, bSeq, _ := ParseID(b)
+	if c := cmp.Compare(aMs, bMs); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(aSeq, bSeq)
+}
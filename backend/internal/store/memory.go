@@ -0,0 +1,413 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+type memoryCacheEntry struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+type memoryRoom struct {
+	messages     []Message
+	lastMs       int64
+	lastSeq      int64
+	maxDeletedID string
+	edits        map[string][]MessageEdit
+	replies      map[string]int
+}
+
+func (r *memoryRoom) nextID() string {
+	ms := time.Now().UnixMilli()
+	if ms <= r.lastMs {
+		ms, r.lastSeq = r.lastMs, r.lastSeq+1
+	} else {
+		r.lastSeq = 0
+	}
+	r.lastMs = ms
+
+	return fmt.Sprintf("%d-%d", ms, r.lastSeq)
+}
+
+func (r *memoryRoom) trim(retention Retention) {
+	trimmed := max(len(r.messages)-retention.MaxMessages, 0)
+	if minID := retention.MinID(time.Now()); minID != "" {
+		for trimmed < len(r.messages) && CompareIDs(r.messages[trimmed].ID, minID) < 0 {
+			trimmed++
+		}
+	}
+	if trimmed == 0 {
+		return
+	}
+	r.maxDeletedID = r.messages[trimmed-1].ID
+	r.messages = append([]Message{}, r.messages[trimmed:]...)
+}
+
+type Memory struct {
+	mu     sync.Mutex
+	rooms  map[string]*memoryRoom
+	topics map[string]string
+	// topicHistory holds the topic changes of each room, newest first.
+	topicHistory  map[string][]TopicChange
+	topicPolicies map[string]string
+	owners        map[string]string
+	subRooms      map[string]map[string]int
+	activity      map[string]map[string]time.Time
+	// online maps roomName -> session ID -> session, present holds the users announced as joined.
+	online   map[string]map[string]memorySession
+	present  map[string]map[string]bool
+	cache    map[string]memoryCacheEntry
+	mods     map[string]map[string]bool
+	policies map[string]Retention
+	// reactions maps roomName -> message ID -> emoji -> userNames.
+	reactions map[string]map[string]map[string][]string
+	pubSub    memoryPubSub
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		rooms:         map[string]*memoryRoom{},
+		topics:        map[string]string{},
+		topicHistory:  map[string][]TopicChange{},
+		topicPolicies: map[string]string{},
+		owners:        map[string]string{},
+		subRooms:      map[string]map[string]int{},
+		activity:      map[string]map[string]time.Time{},
+		online:        map[string]map[string]memorySession{},
+		present:       map[string]map[string]bool{},
+		cache:         map[string]memoryCacheEntry{},
+		mods:          map[string]map[string]bool{},
+		policies:      map[string]Retention{},
+		reactions:     map[string]map[string]map[string][]string{},
+		pubSub:        memoryPubSub{subs: map[string]map[*memorySubscription]struct{}{}},
+	}
+}
+
+func (m *Memory) GetRooms() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	roomNames := make([]string, 0, len(m.rooms))
+	for roomName := range m.rooms {
+		roomNames = append(roomNames, roomName)
+	}
+
+	return roomNames, nil
+}
+
+func (m *Memory) GetLastMessages(roomName string) ([]Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	room, ok := m.rooms[roomName]
+	if !ok {
+		return []Message{}, nil
+	}
+
+	return m.decorate(roomName, append([]Message{}, room.messages...)), nil
+}
+
+func (m *Memory) GetMessagesAfter(roomName, lastID string) ([]Message, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	room, ok := m.rooms[roomName]
+	if !ok {
+		return []Message{}, false, nil
+	}
+	i := len(room.messages)
+	for i > 0 && CompareIDs(room.messages[i-1].ID, lastID) > 0 {
+		i--
+	}
+	gap := room.maxDeletedID != "" && CompareIDs(room.maxDeletedID, lastID) > 0
+
+	return m.decorate(roomName, append([]Message{}, room.messages[i:]...)), gap, nil
+}
+
+func (m *Memory) GetMessages(roomName string, page Page) (MessagesPage, error) {
+	page, err := page.Normalize()
+	if err != nil {
+		return MessagesPage{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	room, ok := m.rooms[roomName]
+	if !ok {
+		return MessagesPage{Messages: []Message{}}, nil
+	}
+
+	messages, hasMore := pageMessages(room.messages, page)
+
+	return MessagesPage{Messages: m.decorate(roomName, messages), HasMore: hasMore}, nil
+}
+
+// pageMessages returns a copy of the page of messages, which must be in chronological order.
+func pageMessages(messages []Message, page Page) ([]Message, bool) {
+	hasMore := false
+	if page.After != "" {
+		start := 0
+		for start < len(messages) && CompareIDs(messages[start].ID, page.After) <= 0 {
+			start++
+		}
+		end := min(start+page.Limit, len(messages))
+		messages, hasMore = messages[start:end], end < len(messages)
+	} else {
+		end := len(messages)
+		for page.Before != "" && end > 0 && CompareIDs(messages[end-1].ID, page.Before) >= 0 {
+			end--
+		}
+		start := max(end-page.Limit, 0)
+		messages, hasMore = messages[start:end], start > 0
+	}
+
+	return append([]Message{}, messages...), hasMore
+}
+
+func (m *Memory) AddMessage(message *Message, roomName string, authenticated bool) (bool, error) {
+	if err := ValidateRoom(roomName); err != nil {
+		return false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	room, exists := m.rooms[roomName]
+	if message.ReplyTo != "" {
+		if _, _, err := ParseID(message.ReplyTo); err != nil {
+			return false, err
+		}
+	}
+	if !exists {
+		if IsTopLevel(roomName) && !AllowTopLevelRooms {
+			return false, fmt.Errorf("memory: error, can not create %s: %w", roomName, ErrTopLevelRoom)
+		}
+		var err error
+		if room, err = m.createRoom(roomName, 1); err != nil {
+			return false, err
+		}
+		if authenticated {
+			m.owners[roomName] = message.UserName
+		}
+	}
+
+	if err := message.SetID(room.nextID()); err != nil {
+		return false, err
+	}
+	room.messages = append(room.messages, *message)
+	room.trim(m.retention(roomName))
+	if message.ReplyTo != "" {
+		if room.replies == nil {
+			room.replies = map[string]int{}
+		}
+		room.replies[message.ReplyTo]++
+	}
+
+	if m.activity[roomName] == nil {
+		m.activity[roomName] = map[string]time.Time{}
+	}
+	m.activity[roomName][message.UserName] = time.UnixMilli(message.Timestamp)
+
+	return !exists, nil
+}
+
+func (m *Memory) CreateRoom(roomName string) (bool, error) {
+	if err := ValidateRoom(roomName); err != nil {
+		return false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.rooms[roomName]; exists {
+		return false, nil
+	}
+	_, err := m.createRoom(roomName, 0)
+
+	return err == nil, err
+}
+
+func (m *Memory) createRoom(roomName string, usersLen int) (*memoryRoom, error) {
+	parentRoom := ParentRoom(roomName)
+	if roomName != "" {
+		if _, ok := m.rooms[parentRoom]; !ok {
+			return nil, fmt.Errorf("memory: error, parent room does not exists for %s", roomName)
+		}
+		if m.subRooms[parentRoom] == nil {
+			m.subRooms[parentRoom] = map[string]int{}
+		}
+		m.subRooms[parentRoom][roomName] = usersLen
+	}
+	room := &memoryRoom{}
+	m.rooms[roomName] = room
+
+	return room, nil
+}
+
+func (m *Memory) GetActiveUsersLen(roomName string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateUserActivity(roomName), nil
+}
+
+func (m *Memory) GetActiveUsers(roomName string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expireUserActivity(roomName)
+	userNames := make([]string, 0, len(m.activity[roomName]))
+	for userName := range m.activity[roomName] {
+		userNames = append(userNames, userName)
+	}
+	activity := m.activity[roomName]
+	sort.Slice(userNames, func(i, j int) bool {
+		if !activity[userNames[i]].Equal(activity[userNames[j]]) {
+			return activity[userNames[i]].After(activity[userNames[j]])
+		}
+
+		return userNames[i] > userNames[j]
+	})
+
+	return userNames, nil
+}
+
+func (m *Memory) GetTopSubRooms(roomName string) ([]ActiveUsersLen, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.subRooms[roomName]) == 0 {
+		return nil, nil
+	}
+
+	subRooms := make([]ActiveUsersLen, 0, len(m.subRooms[roomName]))
+	for subRoom := range m.subRooms[roomName] {
+		m.updateUserActivity(subRoom)
+	}
+	for subRoom, usersLen := range m.subRooms[roomName] {
+		subRooms = append(subRooms, ActiveUsersLen{
+			RoomName:       subRoom,
+			ActiveUsersLen: usersLen,
+			OnlineUsersLen: len(m.onlineUsers(subRoom)),
+		})
+	}
+	sort.Slice(subRooms, func(i, j int) bool {
+		if subRooms[i].ActiveUsersLen != subRooms[j].ActiveUsersLen {
+			return subRooms[i].ActiveUsersLen > subRooms[j].ActiveUsersLen
+		}
+
+		return subRooms[i].RoomName > subRooms[j].RoomName
+	})
+	if len(subRooms) > TopSubRoomsMaxSize {
+		subRooms = subRooms[:TopSubRoomsMaxSize]
+	}
+
+	return subRooms, nil
+}
+
+func (m *Memory) SetTopic(roomName, topic, setBy string) (TopicChange, error) {
+	if err := ValidateRoom(roomName); err != nil {
+		return TopicChange{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.rooms[roomName]; !ok {
+		return TopicChange{}, fmt.Errorf("memory: error, room %s does not exists can not set topic", roomName)
+	}
+	change := TopicChange{Topic: topic, Previous: m.topics[roomName], SetBy: setBy, SetAt: time.Now().UnixMilli()}
+	m.topics[roomName] = topic
+	history := append([]TopicChange{change}, m.topicHistory[roomName]...)
+	m.topicHistory[roomName] = history[:min(len(history), TopicHistoryMaxSize)]
+
+	return change, nil
+}
+
+func (m *Memory) GetTopic(roomName string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	topic, ok := m.topics[roomName]
+	if !ok {
+		return "", fmt.Errorf("memory: error, could not get topic for %s: %w", roomName, ErrNil)
+	}
+
+	return topic, nil
+}
+
+func (m *Memory) GetTopicHistory(roomName string, limit int) ([]TopicChange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	history := m.topicHistory[roomName]
+
+	return append([]TopicChange{}, history[:min(len(history), max(limit, 0))]...), nil
+}
+
+func (m *Memory) GetCache(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.cache[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		delete(m.cache, key)
+
+		return nil, ErrNil
+	}
+
+	return entry.data, nil
+}
+
+func (m *Memory) SetCache(key string, data []byte, expiration time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cache[key] = memoryCacheEntry{data: data, expiresAt: time.Now().Add(expiration)}
+
+	return nil
+}
+
+func (m *Memory) Health() error {
+	return nil
+}
+
+// decorate adds the reply count and the reactions to copies of the room messages.
+func (m *Memory) decorate(roomName string, messages []Message) []Message {
+	room := m.rooms[roomName]
+	for i := range messages {
+		if room != nil {
+			messages[i].Replies = room.replies[messages[i].ID]
+		}
+		if userNames, ok := m.reactions[roomName][messages[i].ID]; ok {
+			messages[i].Reactions = NewReactions(userNames)
+		}
+	}
+
+	return messages
+}
+
+func (m *Memory) updateUserActivity(roomName string) int {
+	m.expireUserActivity(roomName)
+	usersLen := len(m.activity[roomName])
+
+	parentRoom := ParentRoom(roomName)
+	if roomName != parentRoom {
+		if m.subRooms[parentRoom] == nil {
+			m.subRooms[parentRoom] = map[string]int{}
+		}
+		m.subRooms[parentRoom][roomName] = usersLen
+	}
+
+	return usersLen
+}
+
+func (m *Memory) expireUserActivity(roomName string) {
+	deadline := time.Now().Add(-InactiveUserTimeout)
+	for userName, lastSeen := range m.activity[roomName] {
+		if lastSeen.Before(deadline) {
+			delete(m.activity[roomName], userName)
+		}
+	}
+}
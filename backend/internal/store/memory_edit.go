@@ -0,0 +1,122 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+func (m *Memory) GetMessage(roomName, id string) (Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	message := m.findMessage(roomName, id)
+	if message == nil {
+		return Message{}, fmt.Errorf("memory: error, message %s not found in %s: %w", id, roomName, ErrNil)
+	}
+
+	return m.decorate(roomName, []Message{*message})[0], nil
+}
+
+func (m *Memory) EditMessage(roomName, id, text, editedBy string) (Message, error) {
+	return m.changeMessage(roomName, id, MessageEdit{Text: text, EditedBy: editedBy})
+}
+
+func (m *Memory) DeleteMessage(roomName, id, deletedBy string) (Message, error) {
+	return m.changeMessage(roomName, id, MessageEdit{EditedBy: deletedBy, Deleted: true})
+}
+
+func (m *Memory) GetMessageEdits(roomName, id string) ([]MessageEdit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	room, ok := m.rooms[roomName]
+	if !ok {
+		return []MessageEdit{}, nil
+	}
+
+	return append([]MessageEdit{}, room.edits[id]...), nil
+}
+
+func (m *Memory) changeMessage(roomName, id string, edit MessageEdit) (Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	message := m.findMessage(roomName, id)
+	if message == nil {
+		return Message{}, fmt.Errorf("memory: error, message %s not found in %s: %w", id, roomName, ErrNil)
+	}
+	if message.Deleted {
+		return Message{}, fmt.Errorf("memory: error, message %s was deleted", id)
+	}
+	edit.EditedAt = time.Now().UnixMilli()
+	message.Apply(edit)
+	room := m.rooms[roomName]
+	if room.edits == nil {
+		room.edits = map[string][]MessageEdit{}
+	}
+	room.edits[id] = append(room.edits[id], edit)
+
+	return m.decorate(roomName, []Message{*message})[0], nil
+}
+
+func (m *Memory) findMessage(roomName, id string) *Message {
+	room, ok := m.rooms[roomName]
+	if !ok {
+		return nil
+	}
+	for i := range room.messages {
+		if room.messages[i].ID == id {
+			return &room.messages[i]
+		}
+	}
+
+	return nil
+}
+
+func (m *Memory) IsModerator(roomName, userName string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for {
+		if m.mods[roomName][userName] {
+			return true, nil
+		}
+		if roomName == "" {
+			return false, nil
+		}
+		roomName = ParentRoom(roomName)
+	}
+}
+
+func (m *Memory) GetModerators(roomName string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	userNames := make([]string, 0, len(m.mods[roomName]))
+	for userName := range m.mods[roomName] {
+		userNames = append(userNames, userName)
+	}
+
+	return userNames, nil
+}
+
+func (m *Memory) AddModerator(roomName, userName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.mods[roomName] == nil {
+		m.mods[roomName] = map[string]bool{}
+	}
+	m.mods[roomName][userName] = true
+
+	return nil
+}
+
+func (m *Memory) RemoveModerator(roomName, userName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.mods[roomName], userName)
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"sort"
+	"time"
+)
+
+type memorySession struct {
+	userName string
+	lastSeen time.Time
+}
+
+func (m *Memory) AddSession(roomName, userName, sessionID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.online[roomName] == nil {
+		m.online[roomName] = map[string]memorySession{}
+	}
+	m.online[roomName][sessionID] = memorySession{userName: userName, lastSeen: time.Now()}
+	if m.present[roomName] == nil {
+		m.present[roomName] = map[string]bool{}
+	}
+	joined := !m.present[roomName][userName]
+	m.present[roomName][userName] = true
+
+	return joined, nil
+}
+
+func (m *Memory) RemoveSession(roomName, userName, sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.online[roomName], sessionID)
+	if len(m.online[roomName]) == 0 {
+		delete(m.online, roomName)
+	}
+
+	return nil
+}
+
+func (m *Memory) LeaveRoom(roomName, userName string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, onlineUserName := range m.onlineUsers(roomName) {
+		if onlineUserName == userName {
+			return false, nil
+		}
+	}
+	if !m.present[roomName][userName] {
+		return false, nil
+	}
+	delete(m.present[roomName], userName)
+	if len(m.present[roomName]) == 0 {
+		delete(m.present, roomName)
+	}
+
+	return true, nil
+}
+
+func (m *Memory) GetOnlineUsers(roomName string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.onlineUsers(roomName), nil
+}
+
+// onlineUsers drops the sessions not refreshed within OnlineTimeout and returns the sorted users left.
+func (m *Memory) onlineUsers(roomName string) []string {
+	deadline := time.Now().Add(-OnlineTimeout)
+	seen := map[string]bool{}
+	userNames := []string{}
+	for sessionID, session := range m.online[roomName] {
+		if session.lastSeen.Before(deadline) {
+			delete(m.online[roomName], sessionID)
+
+			continue
+		}
+		if !seen[session.userName] {
+			seen[session.userName] = true
+			userNames = append(userNames, session.userName)
+		}
+	}
+	sort.Strings(userNames)
+
+	return userNames
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"log"
+	"sync"
+)
+
+const memorySubscriptionBufferSize = 256
+
+type memoryPubSub struct {
+	mu   sync.Mutex
+	subs map[string]map[*memorySubscription]struct{}
+}
+
+type memorySubscription struct {
+	ps       *memoryPubSub
+	roomName string
+	messages chan []byte
+	closed   bool
+}
+
+func (s *memorySubscription) Messages() <-chan []byte { return s.messages }
+
+func (s *memorySubscription) Close() error {
+	s.ps.mu.Lock()
+	defer s.ps.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	close(s.messages)
+	delete(s.ps.subs[s.roomName], s)
+	if len(s.ps.subs[s.roomName]) == 0 {
+		delete(s.ps.subs, s.roomName)
+	}
+
+	return nil
+}
+
+func (m *Memory) Publish(roomName string, payload []byte) error {
+	m.pubSub.mu.Lock()
+	defer m.pubSub.mu.Unlock()
+
+	for sub := range m.pubSub.subs[roomName] {
+		select {
+		case sub.messages <- payload:
+		default:
+			log.Printf("memory: subscriber of %s is full, dropping event\n", roomName)
+		}
+	}
+
+	return nil
+}
+
+func (m *Memory) Subscribe(roomName string) (Subscription, error) {
+	m.pubSub.mu.Lock()
+	defer m.pubSub.mu.Unlock()
+
+	sub := &memorySubscription{ps: &m.pubSub, roomName: roomName, messages: make(chan []byte, memorySubscriptionBufferSize)}
+	if m.pubSub.subs[roomName] == nil {
+		m.pubSub.subs[roomName] = map[*memorySubscription]struct{}{}
+	}
+	m.pubSub.subs[roomName][sub] = struct{}{}
+
+	return sub, nil
+}
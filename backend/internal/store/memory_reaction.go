@@ -0,0 +1,59 @@
+package store
+
+import "slices"
+
+func (m *Memory) AddReaction(roomName, id, emoji, userName string) ([]Reaction, error) {
+	if err := ValidateReaction(emoji); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reactions := m.messageReactions(roomName, id)
+	if !slices.Contains(reactions[emoji], userName) {
+		reactions[emoji] = append(reactions[emoji], userName)
+	}
+
+	return NewReactions(reactions), nil
+}
+
+func (m *Memory) RemoveReaction(roomName, id, emoji, userName string) ([]Reaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reactions := m.messageReactions(roomName, id)
+	reactions[emoji] = slices.DeleteFunc(reactions[emoji], func(name string) bool { return name == userName })
+	if len(reactions[emoji]) == 0 {
+		delete(reactions, emoji)
+	}
+	if len(reactions) == 0 {
+		delete(m.reactions[roomName], id)
+	}
+
+	return NewReactions(reactions), nil
+}
+
+func (m *Memory) GetReactions(roomName string, ids []string) (map[string][]Reaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reactions := map[string][]Reaction{}
+	for _, id := range ids {
+		if userNames, ok := m.reactions[roomName][id]; ok {
+			reactions[id] = NewReactions(userNames)
+		}
+	}
+
+	return reactions, nil
+}
+
+func (m *Memory) messageReactions(roomName, id string) map[string][]string {
+	if m.reactions[roomName] == nil {
+		m.reactions[roomName] = map[string]map[string][]string{}
+	}
+	if m.reactions[roomName][id] == nil {
+		m.reactions[roomName][id] = map[string][]string{}
+	}
+
+	return m.reactions[roomName][id]
+}
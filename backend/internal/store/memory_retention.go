@@ -0,0 +1,70 @@
+package store
+
+import "maps"
+
+func (m *Memory) GetRetention(roomName string) (RetentionPolicy, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	policy := RetentionPolicy{Effective: m.retention(roomName)}
+	if override, ok := m.policies[roomName]; ok {
+		policy.Override = &override
+	}
+
+	return policy, nil
+}
+
+func (m *Memory) SetRetention(roomName string, retention *Retention) error {
+	if retention != nil {
+		if err := retention.Validate(); err != nil {
+			return err
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if retention == nil {
+		delete(m.policies, roomName)
+	} else {
+		m.policies[roomName] = *retention
+	}
+
+	return nil
+}
+
+func (m *Memory) ApplyRetention(roomName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if room, ok := m.rooms[roomName]; ok {
+		room.trim(m.retention(roomName))
+		if PruneTrimmed {
+			m.pruneTrimmed(roomName, room)
+		}
+	}
+
+	return nil
+}
+
+// pruneTrimmed drops the edits, reactions and reply counts of the messages trimmed from room. Threads are read
+// from the retained messages, so they need no pruning.
+func (m *Memory) pruneTrimmed(roomName string, room *memoryRoom) {
+	isTrimmed := func(id string) bool {
+		return len(room.messages) == 0 || CompareIDs(id, room.messages[0].ID) < 0
+	}
+	maps.DeleteFunc(room.edits, func(id string, _ []MessageEdit) bool { return isTrimmed(id) })
+	maps.DeleteFunc(room.replies, func(id string, _ int) bool { return isTrimmed(id) })
+	maps.DeleteFunc(m.reactions[roomName], func(id string, _ map[string][]string) bool { return isTrimmed(id) })
+}
+
+func (m *Memory) retention(roomName string) Retention {
+	prefixes := RoomPrefixes(roomName)
+	overrides := make([]*Retention, len(prefixes))
+	for i, prefix := range prefixes {
+		if override, ok := m.policies[prefix]; ok {
+			overrides[i] = &override
+		}
+	}
+
+	return InheritRetention(overrides)
+}
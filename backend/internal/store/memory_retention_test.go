@@ -0,0 +1,57 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestApplyRetentionPrunesTrimmed(t *testing.T) {
+	for _, prune := range []bool{true, false} {
+		prune := prune
+		t.Run(fmt.Sprintf("prune=%t", prune), func(t *testing.T) {
+			PruneTrimmed = prune
+			t.Cleanup(func() { PruneTrimmed = true })
+			db := NewMemory()
+			if _, err := db.CreateRoom(""); err != nil {
+				t.Fatal(err)
+			}
+			parent := &Message{UserName: "alice", Text: "parent"}
+			if _, err := db.AddMessage(parent, "lobby", true); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.EditMessage("lobby", parent.ID, "edited", "alice"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.AddReaction("lobby", parent.ID, "👍", "bob"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.AddMessage(&Message{UserName: "bob", Text: "reply", ReplyTo: parent.ID}, "lobby", true); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.SetRetention("lobby", &Retention{MaxMessages: 1}); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.ApplyRetention("lobby"); err != nil {
+				t.Fatal(err)
+			}
+
+			edits, err := db.GetMessageEdits("lobby", parent.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reactions, err := db.GetReactions("lobby", []string{parent.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			replies, err := db.GetReplies("lobby", []string{parent.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, n := range map[string]int{"edits": len(edits), "reactions": len(reactions), "replies": len(replies)} {
+				if (n == 0) != prune {
+					t.Errorf("trimmed parent has %d %s", n, name)
+				}
+			}
+		})
+	}
+}
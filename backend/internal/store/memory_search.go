@@ -0,0 +1,55 @@
+package store
+
+import (
+	"slices"
+	"strings"
+)
+
+func (m *Memory) Search(query SearchQuery) (SearchResults, error) {
+	query, err := query.Normalize()
+	if err != nil {
+		return SearchResults{}, err
+	}
+	terms := SearchTerms(query.Text)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hits := []SearchHit{}
+	for roomName, room := range m.rooms {
+		if !query.InScope(roomName) {
+			continue
+		}
+		for _, message := range room.messages {
+			if message.Deleted || (query.Author != "" && message.UserName != query.Author) {
+				continue
+			}
+			messageTerms := SearchTerms(message.Text)
+			if !containsAll(messageTerms, terms) {
+				continue
+			}
+			hits = append(hits, SearchHit{RoomName: roomName, Message: m.decorate(roomName, []Message{message})[0]})
+		}
+	}
+	slices.SortFunc(hits, func(a, b SearchHit) int {
+		if c := CompareIDs(b.ID, a.ID); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.RoomName, b.RoomName)
+	})
+
+	start := min(query.Offset, len(hits))
+	end := min(start+query.Limit, len(hits))
+
+	return SearchResults{Hits: hits[start:end], HasMore: end < len(hits)}, nil
+}
+
+func containsAll(terms, search []string) bool {
+	for _, term := range search {
+		if !slices.Contains(terms, term) {
+			return false
+		}
+	}
+
+	return true
+}
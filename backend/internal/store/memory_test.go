@@ -0,0 +1,25 @@
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFirstMessageOwnsRoomWhenAuthenticated(t *testing.T) {
+	db := NewMemory()
+	if _, err := db.CreateRoom(""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.AddMessage(&Message{UserName: "alice", Text: "hello"}, "anonymous", false); err != nil {
+		t.Fatal(err)
+	}
+	if owner, err := db.GetRoomOwner("anonymous"); !errors.Is(err, ErrNil) {
+		t.Errorf("room created anonymously is owned by %q, %v", owner, err)
+	}
+	if _, err := db.AddMessage(&Message{UserName: "bob", Text: "hello"}, "authenticated", true); err != nil {
+		t.Fatal(err)
+	}
+	if owner, err := db.GetRoomOwner("authenticated"); err != nil || owner != "bob" {
+		t.Errorf("room owner = %q, %v, want bob", owner, err)
+	}
+}
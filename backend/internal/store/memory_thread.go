@@ -0,0 +1,47 @@
+package store
+
+import "fmt"
+
+func (m *Memory) GetThread(roomName, id string, page Page) (Thread, error) {
+	page, err := page.Normalize()
+	if err != nil {
+		return Thread{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	parent := m.findMessage(roomName, id)
+	if parent == nil {
+		return Thread{}, fmt.Errorf("memory: error, message %s not found in %s: %w", id, roomName, ErrNil)
+	}
+	replies := []Message{}
+	for _, message := range m.rooms[roomName].messages {
+		if message.ReplyTo == id {
+			replies = append(replies, message)
+		}
+	}
+	messages, hasMore := pageMessages(replies, page)
+
+	return Thread{
+		Parent:       m.decorate(roomName, []Message{*parent})[0],
+		MessagesPage: MessagesPage{Messages: m.decorate(roomName, messages), HasMore: hasMore},
+	}, nil
+}
+
+func (m *Memory) GetReplies(roomName string, ids []string) (map[string]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	replies := map[string]int{}
+	room, ok := m.rooms[roomName]
+	if !ok {
+		return replies, nil
+	}
+	for _, id := range ids {
+		if count := room.replies[id]; count > 0 {
+			replies[id] = count
+		}
+	}
+
+	return replies, nil
+}
@@ -0,0 +1,44 @@
+package store
+
+import "fmt"
+
+func (m *Memory) GetTopicPolicy(roomName string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if policy, ok := m.topicPolicies[roomName]; ok {
+		return policy, nil
+	}
+
+	return DefaultTopicPolicy, nil
+}
+
+func (m *Memory) SetTopicPolicy(roomName, policy string) error {
+	if policy != "" {
+		if err := ValidateTopicPolicy(policy); err != nil {
+			return err
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if policy == "" {
+		delete(m.topicPolicies, roomName)
+	} else {
+		m.topicPolicies[roomName] = policy
+	}
+
+	return nil
+}
+
+func (m *Memory) GetRoomOwner(roomName string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	owner, ok := m.owners[roomName]
+	if !ok {
+		return "", fmt.Errorf("memory: error, could not get owner of %s: %w", roomName, ErrNil)
+	}
+
+	return owner, nil
+}
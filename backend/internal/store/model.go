@@ -0,0 +1,38 @@
+package store
+
+// Message ID is its stream ID, unique and ordered within a room, so it can be used to point at the message.
+type Message struct {
+	ID        string     `json:"id"`
+	UserName  string     `json:"userName"`
+	Text      string     `json:"text"`
+	Timestamp int64      `json:"timestamp"`
+	ReplyTo   string     `json:"replyTo,omitempty"`
+	Replies   int        `json:"replies,omitempty"`
+	EditedAt  int64      `json:"editedAt,omitempty"`
+	Deleted   bool       `json:"deleted,omitempty"`
+	Reactions []Reaction `json:"reactions,omitempty"`
+}
+
+// SetID sets the message ID and the timestamp encoded in it.
+func (m *Message) SetID(id string) error {
+	timestamp, _, err := ParseID(id)
+	if err != nil {
+		return err
+	}
+	m.ID, m.Timestamp = id, timestamp
+
+	return nil
+}
+
+// ActiveUsersLen counts the users that chatted recently in a room, and the ones connected to it.
+type ActiveUsersLen struct {
+	RoomName       string `json:"roomName"`
+	ActiveUsersLen int    `json:"activeUsersLength"`
+	OnlineUsersLen int    `json:"onlineUsersLength"`
+}
+
+// Presence lists the users connected to a room and the ones that chatted in it recently.
+type Presence struct {
+	Online   []string `json:"online"`
+	Chatters []string `json:"chatters"`
+}
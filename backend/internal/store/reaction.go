@@ -0,0 +1,43 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+var MaxReactionLength = 32
+
+type Reaction struct {
+	Emoji     string   `json:"emoji"`
+	Count     int      `json:"count"`
+	UserNames []string `json:"userNames"`
+}
+
+func ValidateReaction(emoji string) error {
+	if emoji == "" || len(emoji) > MaxReactionLength || !utf8.ValidString(emoji) || strings.ContainsAny(emoji, " \t\r\n") {
+		return fmt.Errorf("store: error, invalid reaction %q", emoji)
+	}
+
+	return nil
+}
+
+// NewReactions aggregates the users that reacted with each emoji, the most used emojis go first.
+func NewReactions(userNames map[string][]string) []Reaction {
+	reactions := make([]Reaction, 0, len(userNames))
+	for emoji, names := range userNames {
+		if len(names) > 0 {
+			reactions = append(reactions, Reaction{Emoji: emoji, Count: len(names), UserNames: names})
+		}
+	}
+	slices.SortFunc(reactions, func(a, b Reaction) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+
+		return strings.Compare(a.Emoji, b.Emoji)
+	})
+
+	return reactions
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PruneTrimmed makes ApplyRetention also drop what a store keeps about the messages it trims: their edits,
+// reactions, reply counts and threads. An archive keeps serving trimmed messages with all of it, so it is turned
+// off when one is configured.
+var PruneTrimmed = true
+
+// Retention limits how many messages a room keeps and for how long, MaxAge is in seconds. A zero field is
+// inherited from the closest room above that sets it, a MaxAge of -1 keeps messages regardless of their age
+// even when a room above limits it.
+type Retention struct {
+	MaxMessages int   `json:"maxMessages,omitempty"`
+	MaxAge      int64 `json:"maxAge,omitempty"`
+}
+
+// RetentionPolicy is the retention of a room, Override is what the room itself sets and Effective what applies
+// to it once inherited and defaulted.
+type RetentionPolicy struct {
+	Override  *Retention `json:"override"`
+	Effective Retention  `json:"effective"`
+}
+
+func (r Retention) Validate() error {
+	if r.MaxMessages < 0 {
+		return errors.New("store: error, retention maxMessages can not be negative")
+	}
+	if r.MaxAge < -1 {
+		return errors.New("store: error, retention maxAge must be positive, or -1 to disable it")
+	}
+
+	return nil
+}
+
+// MinID returns the oldest message ID kept at now, or "" when messages do not expire.
+func (r Retention) MinID(now time.Time) string {
+	if r.MaxAge <= 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%d-0", now.Add(-time.Duration(r.MaxAge)*time.Second).UnixMilli())
+}
+
+// InheritRetention resolves the effective retention of a room from the overrides of the room and of every room
+// above it, ordered from the room up to the root as RoomPrefixes returns them.
+func InheritRetention(overrides []*Retention) Retention {
+	effective := Retention{}
+	for _, override := range overrides {
+		if override == nil {
+			continue
+		}
+		if effective.MaxMessages == 0 {
+			effective.MaxMessages = override.MaxMessages
+		}
+		if effective.MaxAge == 0 {
+			effective.MaxAge = override.MaxAge
+		}
+	}
+	if effective.MaxMessages == 0 {
+		effective.MaxMessages = RoomMaxMessages
+	}
+	if effective.MaxAge < 0 {
+		effective.MaxAge = 0
+	}
+
+	return effective
+}
@@ -0,0 +1,174 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	RoomNameMaxLength    = 200
+	RoomSegmentMaxLength = 50
+	RoomMaxDepth         = 8
+)
+
+// Reasons a room name is rejected.
+const (
+	RoomEmptySegment     = "empty_segment"
+	RoomInvalidEncoding  = "invalid_encoding"
+	RoomInvalidCharacter = "invalid_character"
+	RoomMixedScripts     = "mixed_scripts"
+	RoomNotCanonical     = "not_canonical"
+	RoomReservedSegment  = "reserved_segment"
+	RoomSegmentTooLong   = "segment_too_long"
+	RoomNameTooLong      = "name_too_long"
+	RoomTooDeep          = "too_deep"
+)
+
+// RoomNameError describes why a room name is invalid, Segment is the index of the offending segment or -1 when
+// the whole name is at fault.
+type RoomNameError struct {
+	Name    string `json:"name"`
+	Reason  string `json:"reason"`
+	Segment int    `json:"segment"`
+	Detail  string `json:"detail"`
+}
+
+func (e *RoomNameError) Error() string {
+	return fmt.Sprintf("store: invalid room name %q: %s", e.Name, e.Detail)
+}
+
+// CanonicalRoom returns the single name a room is known by. Surrounding slashes are dropped, the name is NFC
+// normalized and case folded, and every segment must be made of letters, numbers, combining marks, '-', '_'
+// or '.', without compatibility characters nor letters of scripts that are not written together, within the
+// length and depth limits. The root room is the empty name.
+func CanonicalRoom(name string) (string, error) {
+	if !utf8.ValidString(name) {
+		return "", &RoomNameError{Name: name, Reason: RoomInvalidEncoding, Segment: -1, Detail: "not valid UTF-8"}
+	}
+	canonical := norm.NFC.String(cases.Fold().String(strings.Trim(name, "/")))
+	if canonical == "" {
+		return "", nil
+	}
+	if len(canonical) > RoomNameMaxLength {
+		return "", &RoomNameError{
+			Name: name, Reason: RoomNameTooLong, Segment: -1,
+			Detail: fmt.Sprintf("longer than %d bytes", RoomNameMaxLength),
+		}
+	}
+	segments := strings.Split(canonical, "/")
+	if len(segments) > RoomMaxDepth {
+		return "", &RoomNameError{
+			Name: name, Reason: RoomTooDeep, Segment: -1,
+			Detail: fmt.Sprintf("deeper than %d rooms", RoomMaxDepth),
+		}
+	}
+	for i, segment := range segments {
+		if err := validateSegment(segment); err != "" {
+			return "", &RoomNameError{Name: name, Reason: err, Segment: i, Detail: segmentDetail(err, segment)}
+		}
+	}
+
+	return canonical, nil
+}
+
+// ValidateRoom checks that roomName is already canonical, for the layers that store rooms under their name.
+func ValidateRoom(roomName string) error {
+	canonical, err := CanonicalRoom(roomName)
+	if err != nil {
+		return err
+	}
+	if canonical != roomName {
+		return &RoomNameError{Name: roomName, Reason: RoomNotCanonical, Segment: -1, Detail: "not canonical, use " + canonical}
+	}
+
+	return nil
+}
+
+func validateSegment(segment string) string {
+	switch {
+	case segment == "":
+		return RoomEmptySegment
+	case segment == "." || segment == "..":
+		return RoomReservedSegment
+	case utf8.RuneCountInString(segment) > RoomSegmentMaxLength:
+		return RoomSegmentTooLong
+	}
+	for _, r := range segment {
+		if !unicode.IsLetter(r) && !unicode.IsNumber(r) && !unicode.IsMark(r) && r != '-' && r != '_' && r != '.' {
+			return RoomInvalidCharacter
+		}
+	}
+	// Compatibility characters, like full width letters, look the same as the ones they decompose to.
+	if !norm.NFKC.IsNormalString(segment) {
+		return RoomInvalidCharacter
+	}
+	if mixesScripts(segment) {
+		return RoomMixedScripts
+	}
+
+	return ""
+}
+
+// scriptSets are the scripts a segment may mix, the ones Japanese, Chinese and Korean are written with next to
+// Latin. Any other mix, like Latin with Cyrillic, can spell a name that looks the same as another one.
+var scriptSets = [][]*unicode.RangeTable{
+	{unicode.Latin, unicode.Han, unicode.Hiragana, unicode.Katakana},
+	{unicode.Latin, unicode.Han, unicode.Bopomofo},
+	{unicode.Latin, unicode.Han, unicode.Hangul},
+}
+
+// mixesScripts reports whether the letters of segment belong to scripts that no entry of scriptSets allows
+// together.
+func mixesScripts(segment string) bool {
+	scripts := []*unicode.RangeTable{}
+	for _, r := range segment {
+		if script := letterScript(r); script != nil && !slices.Contains(scripts, script) {
+			scripts = append(scripts, script)
+		}
+	}
+	if len(scripts) < 2 {
+		return false
+	}
+	for _, set := range scriptSets {
+		if !slices.ContainsFunc(scripts, func(script *unicode.RangeTable) bool { return !slices.Contains(set, script) }) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// letterScript returns the script of a letter, nil for other runes and the letters shared by every script.
+func letterScript(r rune) *unicode.RangeTable {
+	if !unicode.IsLetter(r) || unicode.Is(unicode.Common, r) || unicode.Is(unicode.Inherited, r) {
+		return nil
+	}
+	for _, script := range unicode.Scripts {
+		if unicode.Is(script, r) {
+			return script
+		}
+	}
+
+	return nil
+}
+
+func segmentDetail(reason, segment string) string {
+	switch reason {
+	case RoomEmptySegment:
+		return "empty segment"
+	case RoomReservedSegment:
+		return fmt.Sprintf("segment %q is reserved", segment)
+	case RoomSegmentTooLong:
+		return fmt.Sprintf("segment longer than %d characters", RoomSegmentMaxLength)
+	case RoomMixedScripts:
+		return fmt.Sprintf("segment %q mixes letters of different scripts", segment)
+	default:
+		return fmt.Sprintf("segment %q has characters other than letters, numbers, '-', '_' or '.'", segment)
+	}
+}
@@ -0,0 +1,36 @@
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCanonicalRoomScripts(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		reason string
+	}{
+		{name: "paypal"},
+		{name: "раура"},
+		{name: "pаypal", reason: RoomMixedScripts},
+		{name: "lobby/gοogle", reason: RoomMixedScripts},
+		{name: "москва"},
+		{name: "москвa", reason: RoomMixedScripts},
+		{name: "αθήνα-2024"},
+		{name: "東京タワー"},
+		{name: "日本語のroom"},
+		{name: "한국어-chat"},
+		{name: "中文ㄅㄆ"},
+		{name: "한국어タワー", reason: RoomMixedScripts},
+		{name: "café_crème"},
+	} {
+		_, err := CanonicalRoom(test.name)
+		var nameErr *RoomNameError
+		switch {
+		case test.reason == "" && err != nil:
+			t.Errorf("CanonicalRoom(%q) = %s, want it valid", test.name, err)
+		case test.reason != "" && (!errors.As(err, &nameErr) || nameErr.Reason != test.reason):
+			t.Errorf("CanonicalRoom(%q) = %v, want %s", test.name, err, test.reason)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+var (
+	SearchMinTermLength = 2
+	SearchMaxTermLength = 40
+	SearchMaxTerms      = 64
+)
+
+// SearchQuery matches the messages containing every term of Text. It is scoped to Room, or to Room and every
+// room below it when Subtree is set, and to the messages of Author when given.
+type SearchQuery struct {
+	Text    string `json:"text"`
+	Room    string `json:"room"`
+	Subtree bool   `json:"subtree,omitempty"`
+	Author  string `json:"author,omitempty"`
+	Offset  int    `json:"offset,omitempty"`
+	Limit   int    `json:"limit,omitempty"`
+}
+
+type SearchHit struct {
+	RoomName string `json:"roomName"`
+	Message
+}
+
+// SearchResults holds the hits newest first, HasMore reports there are more after Offset+Limit.
+type SearchResults struct {
+	Hits    []SearchHit `json:"hits"`
+	HasMore bool        `json:"hasMore"`
+}
+
+func (q SearchQuery) Normalize() (SearchQuery, error) {
+	if len(SearchTerms(q.Text)) == 0 {
+		return q, errors.New("store: error, search has no terms")
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultPageSize
+	}
+	q.Limit = min(q.Limit, MaxPageSize)
+
+	return q, nil
+}
+
+// InScope reports whether roomName is searched by the query.
+func (q SearchQuery) InScope(roomName string) bool {
+	if roomName == q.Room {
+		return true
+	}
+
+	return q.Subtree && (q.Room == "" || strings.HasPrefix(roomName, q.Room+"/"))
+}
+
+// SearchTerms splits text into the distinct lower case words that are indexed and searched.
+func SearchTerms(text string) []string {
+	terms := []string{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if len([]rune(word)) < SearchMinTermLength || len(word) > SearchMaxTermLength || slices.Contains(terms, word) {
+			continue
+		}
+		terms = append(terms, word)
+		if len(terms) == SearchMaxTerms {
+			break
+		}
+	}
+
+	return terms
+}
+
+// RoomPrefixes returns roomName and every room above it, up to the root room.
+func RoomPrefixes(roomName string) []string {
+	prefixes := []string{roomName}
+	for roomName != "" {
+		roomName = ParentRoom(roomName)
+		prefixes = append(prefixes, roomName)
+	}
+
+	return prefixes
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	// RoomMaxMessages is the number of messages kept by rooms without a retention that sets it.
+	RoomMaxMessages    = 200
+	TopSubRoomsMaxSize = 100
+	// InactiveUserTimeout is how long a user is still counted as active in a room after their last message.
+	InactiveUserTimeout = 1 * time.Hour
+	// OnlineTimeout is how long a session stays online without being refreshed, covering instances that stop
+	// without removing their sessions.
+	OnlineTimeout = 2 * time.Minute
+	// AllowTopLevelRooms lets the first message of a room create it right below the root room, otherwise only
+	// rooms created with CreateRoom are available at the top level.
+	AllowTopLevelRooms = true
+)
+
+var (
+	ErrNil          = errors.New("store: nil returned")
+	ErrUnavailable  = errors.New("store: chat unavailable")
+	ErrTopLevelRoom = errors.New("store: top-level rooms can not be created by messages")
+)
+
+type Store interface {
+	GetRooms() ([]string, error)
+	GetLastMessages(roomName string) ([]Message, error)
+	// GetMessagesAfter returns the retained messages newer than lastID, gap reports that some of them were
+	// already trimmed.
+	GetMessagesAfter(roomName, lastID string) (messages []Message, gap bool, err error)
+	GetMessages(roomName string, page Page) (MessagesPage, error)
+	// AddMessage adds message to the room, creating the room when it is new, owned by the sender only when they are
+	// authenticated. A message with ReplyTo is also added to the thread of the message it replies to, which
+	// ResolveReplyTo must have checked first.
+	AddMessage(message *Message, roomName string, authenticated bool) (bool, error)
+	GetThread(roomName, id string, page Page) (Thread, error)
+	// GetReplies returns the reply counts of the given messages by message ID, messages without any are left out.
+	GetReplies(roomName string, ids []string) (map[string]int, error)
+	// CreateRoom creates an empty room under its existing parent, the root room has none. It reports whether the
+	// room was created, creating an existing room is not an error.
+	CreateRoom(roomName string) (bool, error)
+	GetMessage(roomName, id string) (Message, error)
+	EditMessage(roomName, id, text, editedBy string) (Message, error)
+	DeleteMessage(roomName, id, deletedBy string) (Message, error)
+	GetMessageEdits(roomName, id string) ([]MessageEdit, error)
+	Search(query SearchQuery) (SearchResults, error)
+	AddReaction(roomName, id, emoji, userName string) ([]Reaction, error)
+	RemoveReaction(roomName, id, emoji, userName string) ([]Reaction, error)
+	// GetReactions returns the reactions of the given messages by message ID, messages without any are left out.
+	GetReactions(roomName string, ids []string) (map[string][]Reaction, error)
+	IsModerator(roomName, userName string) (bool, error)
+	GetModerators(roomName string) ([]string, error)
+	AddModerator(roomName, userName string) error
+	RemoveModerator(roomName, userName string) error
+	GetRetention(roomName string) (RetentionPolicy, error)
+	// SetRetention overrides the retention of a room and the rooms below it, nil removes the override.
+	SetRetention(roomName string, retention *Retention) error
+	// ApplyRetention drops the messages of a room that its retention no longer keeps.
+	ApplyRetention(roomName string) error
+	GetActiveUsersLen(roomName string) (int, error)
+	GetActiveUsers(roomName string) ([]string, error)
+	GetTopSubRooms(roomName string) ([]ActiveUsersLen, error)
+	// AddSession marks a websocket session of userName as online in the room until OnlineTimeout, adding it again
+	// refreshes it. It reports whether the user joined the room, that is whether they were not present yet.
+	AddSession(roomName, userName, sessionID string) (bool, error)
+	RemoveSession(roomName, userName, sessionID string) error
+	// LeaveRoom reports whether userName left the room, which happens once when none of their sessions is online.
+	LeaveRoom(roomName, userName string) (bool, error)
+	GetOnlineUsers(roomName string) ([]string, error)
+	// SetTopic sets the topic of an existing room and records the change in its topic history.
+	SetTopic(roomName, topic, setBy string) (TopicChange, error)
+	GetTopic(roomName string) (string, error)
+	// GetTopicHistory returns up to limit of the latest topic changes of a room, newest first.
+	GetTopicHistory(roomName string, limit int) ([]TopicChange, error)
+	// GetTopicPolicy returns the topic policy of a room, DefaultTopicPolicy when it has none.
+	GetTopicPolicy(roomName string) (string, error)
+	// SetTopicPolicy sets the topic policy of a room, an empty policy removes it.
+	SetTopicPolicy(roomName, policy string) error
+	// GetRoomOwner returns the authenticated user whose message created the room, ErrNil for rooms created by
+	// CreateRoom or by an anonymous message.
+	GetRoomOwner(roomName string) (string, error)
+	GetCache(key string) ([]byte, error)
+	SetCache(key string, data []byte, expiration time.Duration) error
+	Publish(roomName string, payload []byte) error
+	Subscribe(roomName string) (Subscription, error)
+	Health() error
+}
+
+// Subscription receives every payload published to a room, from this or any other instance.
+type Subscription interface {
+	Messages() <-chan []byte
+	Close() error
+}
+
+func IsTopLevel(roomName string) bool {
+	return roomName != "" && !strings.Contains(roomName, "/")
+}
+
+func ParentRoom(roomName string) string {
+	rooms := strings.Split(roomName, "/")
+
+	return strings.Join(rooms[:len(rooms)-1], "/")
+}
@@ -0,0 +1,34 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Thread is a page of the replies to Parent. Replies to a reply belong to the thread of the message it replies
+// to, so threads are never nested.
+type Thread struct {
+	Parent Message `json:"parent"`
+	MessagesPage
+}
+
+// ResolveReplyTo checks the message replied to exists in db and points the reply at the root of its thread, as
+// AddMessage expects it. Resolving it through a store that also serves archived messages allows replying to
+// messages the room no longer retains.
+func ResolveReplyTo(db Store, roomName string, message *Message) error {
+	if _, _, err := ParseID(message.ReplyTo); err != nil {
+		return err
+	}
+	parent, err := db.GetMessage(roomName, message.ReplyTo)
+	if errors.Is(err, ErrNil) {
+		return fmt.Errorf("store: error, replied message %s not found in %s: %w", message.ReplyTo, roomName, ErrNil)
+	}
+	if err != nil {
+		return err
+	}
+	if parent.ReplyTo != "" {
+		message.ReplyTo = parent.ReplyTo
+	}
+
+	return nil
+}
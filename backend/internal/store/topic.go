@@ -0,0 +1,39 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// TopicHistoryMaxSize is the number of topic changes kept by each room.
+var TopicHistoryMaxSize = 50
+
+// TopicChange is an entry of the topic history of a room, SetBy is empty for topics not set by a user, like
+// seeded ones.
+type TopicChange struct {
+	Topic    string `json:"topic"`
+	Previous string `json:"previous"`
+	SetBy    string `json:"setBy,omitempty"`
+	SetAt    int64  `json:"setAt"`
+}
+
+// Topic policies, who besides admins can change the topic of a room.
+const (
+	TopicOpen      = "open"      // Any user.
+	TopicModerated = "moderated" // The room owner and the moderators of the room or of any room above it.
+	TopicLocked    = "locked"    // Nobody.
+)
+
+// DefaultTopicPolicy applies to the rooms without a topic policy of their own.
+var DefaultTopicPolicy = TopicModerated
+
+var ErrInvalidTopicPolicy = errors.New("store: invalid topic policy")
+
+func ValidateTopicPolicy(policy string) error {
+	switch policy {
+	case TopicOpen, TopicModerated, TopicLocked:
+		return nil
+	}
+
+	return fmt.Errorf("%w %q, it must be %s, %s or %s", ErrInvalidTopicPolicy, policy, TopicOpen, TopicModerated, TopicLocked)
+}
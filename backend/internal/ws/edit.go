@@ -0,0 +1,93 @@
+package ws
+
+import (
+	"copuchat/internal/store"
+	"errors"
+	"fmt"
+)
+
+var ErrForbidden = errors.New("ws: forbidden")
+
+type EditRequest struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+}
+
+type DeleteRequest struct {
+	ID string `json:"id"`
+}
+
+// CanModify reports whether userName may edit or delete message, only its author and the moderators of the
+// room, or of any room above it, can.
+func CanModify(db store.Store, roomName, userName string, message store.Message) (bool, error) {
+	if message.UserName == userName {
+		return true, nil
+	}
+	isModerator, err := db.IsModerator(roomName, userName)
+	if err != nil {
+		return false, fmt.Errorf("ws: error checking moderators: %w", err)
+	}
+
+	return isModerator, nil
+}
+
+func EditMessage(db store.Store, roomName, userName string, edit EditRequest) (store.Message, error) {
+	if edit.Text == "" {
+		return store.Message{}, fmt.Errorf("ws: error, can not edit message %s to an empty text", edit.ID)
+	}
+	if err := authorizeChange(db, roomName, userName, edit.ID); err != nil {
+		return store.Message{}, err
+	}
+	message, err := db.EditMessage(roomName, edit.ID, edit.Text, userName)
+	if err != nil {
+		return store.Message{}, fmt.Errorf("ws: error editing message: %w", err)
+	}
+	if err := Publish(db, roomName, Event{Type: "MessageEdited", Data: message}); err != nil {
+		return message, fmt.Errorf("ws: error broadcasting edit: %w", err)
+	}
+
+	return message, nil
+}
+
+func DeleteMessage(db store.Store, roomName, userName, id string) (store.Message, error) {
+	if err := authorizeChange(db, roomName, userName, id); err != nil {
+		return store.Message{}, err
+	}
+	message, err := db.DeleteMessage(roomName, id, userName)
+	if err != nil {
+		return store.Message{}, fmt.Errorf("ws: error deleting message: %w", err)
+	}
+	if err := Publish(db, roomName, Event{Type: "MessageDeleted", Data: message}); err != nil {
+		return message, fmt.Errorf("ws: error broadcasting delete: %w", err)
+	}
+
+	return message, nil
+}
+
+// requireAuthenticated rejects the requests of anonymous sessions, whose user name anyone could have picked.
+func requireAuthenticated(session *Session) error {
+	if !session.Authenticated {
+		return fmt.Errorf("%w: %s is not authenticated", ErrForbidden, session.UserName)
+	}
+
+	return nil
+}
+
+func authorizeChange(db store.Store, roomName, userName, id string) error {
+	if _, _, err := store.ParseID(id); err != nil {
+		return err
+	}
+	message, err := db.GetMessage(roomName, id)
+	if err != nil {
+		return fmt.Errorf("ws: error getting message %s: %w", id, err)
+	}
+	allowed, err := CanModify(db, roomName, userName, message)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return fmt.Errorf("%w: %s can not change message %s", ErrForbidden, userName, id)
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package ws
+
+import (
+	"copuchat/internal/store"
+	"fmt"
+	"log"
+)
+
+// Publish sends event to every hub of roomName across all instances sharing the store.
+func Publish(db store.Store, roomName string, event Event) error {
+	frame, err := NewFrame(event)
+	if err != nil {
+		return err
+	}
+	if err := db.Publish(roomName, frame); err != nil {
+		return fmt.Errorf("ws: error publishing %s event: %w", event.Type, err)
+	}
+
+	return nil
+}
+
+// subscribe is only called by the join that creates the hub, and unsubscribe by the leave that removes it from
+// the Registry, both without holding its lock.
+func (h *Hub) subscribe(db store.Store) error {
+	sub, err := db.Subscribe(h.RoomName)
+	if err != nil {
+		return fmt.Errorf("ws: error subscribing to room %s: %w", h.RoomName, err)
+	}
+	h.sub = sub
+	h.stop = make(chan struct{})
+	go h.checkUpdates(db, h.stop)
+	go func() {
+		for data := range sub.Messages() {
+			h.broadcastFrame(data)
+		}
+	}()
+
+	return nil
+}
+
+func (h *Hub) unsubscribe() {
+	if h.sub == nil {
+		return
+	}
+	if err := h.sub.Close(); err != nil {
+		log.Printf("%s\n", err)
+	}
+	close(h.stop)
+	h.sub = nil
+}
@@ -0,0 +1,64 @@
+package ws
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+var (
+	PingInterval = 30 * time.Second
+	ReadTimeout  = 75 * time.Second
+	IdleTimeout  time.Duration // Zero disables closing sessions that don't send messages.
+)
+
+// deadlineConn pushes the read deadline forward before every read, so any frame from the client, pongs
+// included, keeps the connection alive while a half-open one times out after ReadTimeout.
+type deadlineConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *deadlineConn) Read(b []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+
+	return c.Conn.Read(b)
+}
+
+type deadlineResponseWriter struct {
+	http.ResponseWriter
+	timeout time.Duration
+	conn    net.Conn // The hijacked connection, set once the websocket handshake hijacks it.
+}
+
+func (w *deadlineResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("ws: response writer does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	buffered, err := rw.Reader.Peek(rw.Reader.Buffered())
+	if err != nil {
+		return nil, nil, err
+	}
+	deadline := &deadlineConn{Conn: conn, timeout: w.timeout}
+	w.conn = deadline
+	reader := bufio.NewReader(io.MultiReader(bytes.NewReader(bytes.Clone(buffered)), deadline))
+
+	return deadline, bufio.NewReadWriter(reader, rw.Writer), nil
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -2,7 +2,7 @@ package ws
 
 import (
 	"bytes"
-	"copuchat/internal/redis"
+	"copuchat/internal/store"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -19,7 +19,7 @@ import (
 
 const cacheKeyPrefix = "cache:"
 
-func LinkPreviewGraph(rawURL string, hub *Hub, userName string) (*opengraph.OpenGraph, error) {
+func LinkPreviewGraph(db store.Store, rawURL, remoteAddr string) (*opengraph.OpenGraph, error) {
 	url, err := parseURL(rawURL)
 	if err != nil {
 		return nil, err
@@ -27,8 +27,8 @@ func LinkPreviewGraph(rawURL string, hub *Hub, userName string) (*opengraph.Open
 	var graph *opengraph.OpenGraph
 	cacheKey := cacheKeyPrefix + url
 
-	data, err := redis.Get(cacheKey)
-	if err != nil && !errors.Is(err, redis.ErrNil) {
+	data, err := db.GetCache(cacheKey)
+	if err != nil && !errors.Is(err, store.ErrNil) {
 		return nil, err
 	}
 	if err == nil {
@@ -36,10 +36,7 @@ func LinkPreviewGraph(rawURL string, hub *Hub, userName string) (*opengraph.Open
 			return nil, err
 		}
 	}
-	if errors.Is(err, redis.ErrNil) {
-		hub.Lock()
-		remoteAddr := hub.Conns[userName].Request().RemoteAddr
-		hub.Unlock()
+	if errors.Is(err, store.ErrNil) {
 		if graph, err = fetchOpenGraph(url, remoteAddr); err != nil {
 			return nil, err
 		}
@@ -47,7 +44,7 @@ func LinkPreviewGraph(rawURL string, hub *Hub, userName string) (*opengraph.Open
 		if err != nil {
 			return nil, err
 		}
-		if err := redis.SetPX(cacheKey, data, cacheExpirationTime); err != nil {
+		if err := db.SetCache(cacheKey, data, cacheExpirationTime); err != nil {
 			return nil, err
 		}
 	}
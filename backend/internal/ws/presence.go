@@ -0,0 +1,68 @@
+package ws
+
+import (
+	"copuchat/internal/store"
+	"fmt"
+	"log"
+	"time"
+)
+
+// PresenceDebounce is how long a user that closed their last session has to reconnect before a Leave event is
+// sent, so quick reconnects are not announced.
+var PresenceDebounce = 5 * time.Second
+
+// UserPresence is the data of the Join and Leave events.
+type UserPresence struct {
+	UserName string `json:"userName"`
+}
+
+// keepOnline marks the session as online until it is closed, refreshing it every PingInterval. Join and Leave
+// events are published to the room when the user joins or, after PresenceDebounce, leaves it.
+func keepOnline(db store.Store, session *Session) {
+	roomName, userName := session.RoomName, session.UserName
+	joined, err := db.AddSession(roomName, userName, session.ID)
+	if err != nil {
+		log.Printf("ws: error adding session: %s\n", err)
+	}
+	if joined {
+		if err := Publish(db, roomName, Event{Type: "Join", Data: UserPresence{UserName: userName}}); err != nil {
+			log.Printf("%s\n", err)
+		}
+		scheduleUpdates(db, roomName)
+	}
+
+	ticker := time.NewTicker(PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-session.done:
+			if err := db.RemoveSession(roomName, userName, session.ID); err != nil {
+				log.Printf("ws: error removing session: %s\n", err)
+			}
+			time.AfterFunc(PresenceDebounce, func() {
+				if err := leaveRoom(db, roomName, userName); err != nil {
+					log.Printf("%s\n", err)
+				}
+			})
+
+			return
+		case <-ticker.C:
+			if _, err := db.AddSession(roomName, userName, session.ID); err != nil {
+				log.Printf("ws: error refreshing session: %s\n", err)
+			}
+		}
+	}
+}
+
+func leaveRoom(db store.Store, roomName, userName string) error {
+	left, err := db.LeaveRoom(roomName, userName)
+	if err != nil {
+		return fmt.Errorf("ws: error leaving room: %w", err)
+	}
+	if !left {
+		return nil
+	}
+	scheduleUpdates(db, roomName)
+
+	return Publish(db, roomName, Event{Type: "Leave", Data: UserPresence{UserName: userName}})
+}
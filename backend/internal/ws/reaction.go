@@ -0,0 +1,46 @@
+package ws
+
+import (
+	"copuchat/internal/store"
+	"fmt"
+)
+
+type ReactionRequest struct {
+	ID     string `json:"id"`
+	Emoji  string `json:"emoji"`
+	Remove bool   `json:"remove,omitempty"`
+}
+
+// Reactions is the data of a Reaction event, the aggregated reactions of a message after a change.
+type Reactions struct {
+	ID        string           `json:"id"`
+	Reactions []store.Reaction `json:"reactions"`
+}
+
+func React(db store.Store, roomName, userName string, request ReactionRequest) ([]store.Reaction, error) {
+	if _, _, err := store.ParseID(request.ID); err != nil {
+		return nil, err
+	}
+	message, err := db.GetMessage(roomName, request.ID)
+	if err != nil {
+		return nil, fmt.Errorf("ws: error getting message %s: %w", request.ID, err)
+	}
+	if message.Deleted {
+		return nil, fmt.Errorf("ws: error, can not react to deleted message %s", request.ID)
+	}
+
+	var reactions []store.Reaction
+	if request.Remove {
+		reactions, err = db.RemoveReaction(roomName, request.ID, request.Emoji, userName)
+	} else {
+		reactions, err = db.AddReaction(roomName, request.ID, request.Emoji, userName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ws: error updating reactions: %w", err)
+	}
+	if err := Publish(db, roomName, Event{Type: "Reaction", Data: Reactions{ID: request.ID, Reactions: reactions}}); err != nil {
+		return reactions, fmt.Errorf("ws: error broadcasting reaction: %w", err)
+	}
+
+	return reactions, nil
+}
@@ -0,0 +1,100 @@
+package ws
+
+import (
+	"copuchat/internal/store"
+	"sync"
+)
+
+// Registry owns the hubs of this instance. A hub lives as long as it has at least one session, joining and
+// leaving are atomic with respect to its creation and teardown.
+type Registry struct {
+	db   store.Store
+	mu   sync.Mutex
+	hubs map[string]*Hub
+}
+
+// RegistryStats counts the hubs of this instance, with their users and sessions.
+type RegistryStats struct {
+	Hubs     int `json:"hubs"`
+	Users    int `json:"users"`
+	Sessions int `json:"sessions"`
+}
+
+func NewRegistry(db store.Store) *Registry {
+	return &Registry{db: db, hubs: map[string]*Hub{}}
+}
+
+func (r *Registry) Join(roomName string, session *Session) (*Hub, error) {
+	if err := store.ValidateRoom(roomName); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	hub, ok := r.hubs[roomName]
+	if !ok {
+		hub = &Hub{RoomName: roomName, Sessions: map[string]map[string]*Session{}, ready: make(chan struct{})}
+		r.hubs[roomName] = hub
+	}
+	hub.joining++
+	r.mu.Unlock()
+
+	// Subscribing is a round trip to the store, so it is done without the registry lock and the other joins to
+	// the room wait for it.
+	if !ok {
+		hub.err = hub.subscribe(r.db)
+		close(hub.ready)
+	}
+	<-hub.ready
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hub.joining--
+	if hub.err != nil {
+		if hub.joining == 0 && r.hubs[roomName] == hub {
+			delete(r.hubs, roomName)
+		}
+
+		return nil, hub.err
+	}
+	hub.Lock()
+	if hub.Sessions[session.UserName] == nil {
+		hub.Sessions[session.UserName] = map[string]*Session{}
+	}
+	hub.Sessions[session.UserName][session.ID] = session
+	hub.Unlock()
+
+	return hub, nil
+}
+
+func (r *Registry) Leave(hub *Hub, session *Session) {
+	r.mu.Lock()
+	hub.Lock()
+	delete(hub.Sessions[session.UserName], session.ID)
+	if len(hub.Sessions[session.UserName]) == 0 {
+		delete(hub.Sessions, session.UserName)
+	}
+	empty := len(hub.Sessions) == 0 && hub.joining == 0
+	hub.Unlock()
+	teardown := empty && r.hubs[hub.RoomName] == hub
+	if teardown {
+		delete(r.hubs, hub.RoomName)
+	}
+	r.mu.Unlock()
+
+	if teardown {
+		hub.unsubscribe()
+	}
+}
+
+func (r *Registry) Stats() RegistryStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := RegistryStats{Hubs: len(r.hubs)}
+	for _, hub := range r.hubs {
+		stats.Users += hub.UsersLen()
+		stats.Sessions += hub.SessionsLen()
+	}
+
+	return stats
+}
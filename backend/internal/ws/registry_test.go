@@ -0,0 +1,158 @@
+package ws
+
+import (
+	"copuchat/internal/store"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const testRoom = "lobby"
+
+func newTestServer(t *testing.T) (*Registry, *httptest.Server) {
+	t.Helper()
+	db := store.NewMemory()
+	if _, err := db.CreateRoom(""); err != nil {
+		t.Fatal(err)
+	}
+	registry := NewRegistry(db)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		registry.Handler(testRoom, req.URL.Query().Get("userName"), false, "").ServeHTTP(w, req)
+	}))
+	t.Cleanup(server.Close)
+
+	return registry, server
+}
+
+func dial(t *testing.T, server *httptest.Server, userName string) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/?userName=" + userName
+	conn, err := websocket.Dial(url, "", server.URL)
+	if err != nil {
+		t.Error(err)
+
+		return nil
+	}
+
+	return conn
+}
+
+// receive reads events from conn until one of type eventType arrives.
+func receive(conn *websocket.Conn, eventType string) (string, error) {
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		return "", err
+	}
+	for {
+		var data string
+		if err := websocket.Message.Receive(conn, &data); err != nil {
+			return "", err
+		}
+		if strings.Contains(data, fmt.Sprintf(`"type":%q,`, eventType)) {
+			return data, nil
+		}
+	}
+}
+
+func waitStats(t *testing.T, registry *Registry, want RegistryStats) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for registry.Stats() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("stats = %+v, want %+v", registry.Stats(), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRegistryConcurrentJoins(t *testing.T) {
+	const sessions = 50
+	registry, server := newTestServer(t)
+
+	conns := make([]*websocket.Conn, sessions)
+	var wg sync.WaitGroup
+	for i := range conns {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Two sessions per user, to check users and sessions are counted apart.
+			conn := dial(t, server, fmt.Sprintf("user%d", i/2))
+			if conn == nil {
+				return
+			}
+			if _, err := receive(conn, "Messages"); err != nil {
+				t.Error(err)
+			}
+			conns[i] = conn
+		}()
+	}
+	wg.Wait()
+	if t.Failed() {
+		t.FailNow()
+	}
+	waitStats(t, registry, RegistryStats{Hubs: 1, Users: sessions / 2, Sessions: sessions})
+
+	if err := websocket.JSON.Send(conns[0], map[string]string{"text": "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, conn := range conns {
+		conn := conn
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := receive(conn, "Message")
+			if err != nil {
+				t.Error(err)
+			} else if !strings.Contains(data, "hello") {
+				t.Errorf("got %s, want the hello message", data)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, conn := range conns {
+		conn := conn
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := conn.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	waitStats(t, registry, RegistryStats{})
+}
+
+func TestRegistryRejoinAfterTeardown(t *testing.T) {
+	registry, server := newTestServer(t)
+
+	for round := 0; round < 20; round++ {
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			i := i
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				conn := dial(t, server, fmt.Sprintf("user%d", i))
+				if conn == nil {
+					return
+				}
+				if _, err := receive(conn, "Messages"); err != nil {
+					t.Error(err)
+				}
+				if err := conn.Close(); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	waitStats(t, registry, RegistryStats{})
+}
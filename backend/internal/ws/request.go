@@ -0,0 +1,176 @@
+package ws
+
+import (
+	"copuchat/internal/store"
+	"encoding/json"
+	"fmt"
+)
+
+// Request is an event sent by a client. A bare message object without a type, as older clients send it, is
+// read as a Message request.
+type Request struct {
+	Type string          `json:"type"` // Message | Resume | History | Thread | Edit | Delete | Reaction.
+	Data json.RawMessage `json:"data"`
+}
+
+type ResumeRequest struct {
+	LastID string `json:"lastId"`
+}
+
+// History answers a History request with the requested page, echoing it so the client can match them.
+type History struct {
+	store.Page
+	store.MessagesPage
+}
+
+// Resume carries the messages a reconnecting client missed since LastID. Gap is set when some of them are no
+// longer retained, so the client knows its history has a hole right before Messages.
+type Resume struct {
+	LastID   string          `json:"lastId"`
+	Messages []store.Message `json:"messages"`
+	Gap      bool            `json:"gap"`
+}
+
+// requestHandler handles the decoded data of a request of one type.
+type requestHandler func(db store.Store, hub *Hub, session *Session, data json.RawMessage) error
+
+var requestHandlers = map[string]requestHandler{
+	"Message":  handleMessageRequest,
+	"Resume":   handleResumeRequest,
+	"History":  handleHistoryRequest,
+	"Thread":   handleThreadRequest,
+	"Edit":     handleEditRequest,
+	"Delete":   handleDeleteRequest,
+	"Reaction": handleReactionRequest,
+}
+
+func handleRequest(db store.Store, hub *Hub, session *Session, data []byte) error {
+	var request Request
+	if err := json.Unmarshal(data, &request); err != nil {
+		return fmt.Errorf("ws: error decoding request: %w", err)
+	}
+	if request.Type == "" {
+		request.Type, request.Data = "Message", data
+	}
+	handler, ok := requestHandlers[request.Type]
+	if !ok {
+		return fmt.Errorf("ws: unknown request type %s", request.Type)
+	}
+
+	return handler(db, hub, session, request.Data)
+}
+
+func handleMessageRequest(db store.Store, hub *Hub, session *Session, data json.RawMessage) error {
+	var message *store.Message
+	if err := json.Unmarshal(data, &message); err != nil {
+		return fmt.Errorf("ws: error decoding message: %w", err)
+	}
+	if message == nil || message.Text == "" {
+		return nil
+	}
+	message = &store.Message{UserName: session.UserName, Text: message.Text, ReplyTo: message.ReplyTo}
+	if message.ReplyTo != "" {
+		if err := store.ResolveReplyTo(db, hub.RoomName, message); err != nil {
+			return fmt.Errorf("ws: error resolving reply: %w", err)
+		}
+	}
+
+	return handleMessage(db, hub, session, message)
+}
+
+func handleResumeRequest(db store.Store, _ *Hub, session *Session, data json.RawMessage) error {
+	var resume ResumeRequest
+	if err := json.Unmarshal(data, &resume); err != nil {
+		return fmt.Errorf("ws: error decoding resume: %w", err)
+	}
+
+	return sendMissedMessages(db, session, resume.LastID)
+}
+
+func handleHistoryRequest(db store.Store, _ *Hub, session *Session, data json.RawMessage) error {
+	var page store.Page
+	if err := json.Unmarshal(data, &page); err != nil {
+		return fmt.Errorf("ws: error decoding history page: %w", err)
+	}
+
+	return sendHistory(db, session, page)
+}
+
+func handleThreadRequest(db store.Store, _ *Hub, session *Session, data json.RawMessage) error {
+	var thread ThreadRequest
+	if err := json.Unmarshal(data, &thread); err != nil {
+		return fmt.Errorf("ws: error decoding thread: %w", err)
+	}
+
+	return sendThread(db, session, thread)
+}
+
+func handleEditRequest(db store.Store, hub *Hub, session *Session, data json.RawMessage) error {
+	if err := requireAuthenticated(session); err != nil {
+		return err
+	}
+	var edit EditRequest
+	if err := json.Unmarshal(data, &edit); err != nil {
+		return fmt.Errorf("ws: error decoding edit: %w", err)
+	}
+	_, err := EditMessage(db, hub.RoomName, session.UserName, edit)
+
+	return err
+}
+
+func handleDeleteRequest(db store.Store, hub *Hub, session *Session, data json.RawMessage) error {
+	if err := requireAuthenticated(session); err != nil {
+		return err
+	}
+	var remove DeleteRequest
+	if err := json.Unmarshal(data, &remove); err != nil {
+		return fmt.Errorf("ws: error decoding delete: %w", err)
+	}
+	_, err := DeleteMessage(db, hub.RoomName, session.UserName, remove.ID)
+
+	return err
+}
+
+func handleReactionRequest(db store.Store, hub *Hub, session *Session, data json.RawMessage) error {
+	if err := requireAuthenticated(session); err != nil {
+		return err
+	}
+	var reaction ReactionRequest
+	if err := json.Unmarshal(data, &reaction); err != nil {
+		return fmt.Errorf("ws: error decoding reaction: %w", err)
+	}
+	_, err := React(db, hub.RoomName, session.UserName, reaction)
+
+	return err
+}
+
+func sendMissedMessages(db store.Store, session *Session, lastID string) error {
+	if _, _, err := store.ParseID(lastID); err != nil {
+		return err
+	}
+	messages, gap, err := db.GetMessagesAfter(session.RoomName, lastID)
+	if err != nil {
+		return fmt.Errorf("ws: error getting missed messages: %w", err)
+	}
+	if err := session.Send(Event{Type: "Resume", Data: Resume{LastID: lastID, Messages: messages, Gap: gap}}); err != nil {
+		return fmt.Errorf("ws: error sending missed messages: %w", err)
+	}
+
+	return nil
+}
+
+func sendHistory(db store.Store, session *Session, page store.Page) error {
+	page, err := page.Normalize()
+	if err != nil {
+		return err
+	}
+	messages, err := db.GetMessages(session.RoomName, page)
+	if err != nil {
+		return fmt.Errorf("ws: error getting history: %w", err)
+	}
+	if err := session.Send(Event{Type: "History", Data: History{Page: page, MessagesPage: messages}}); err != nil {
+		return fmt.Errorf("ws: error sending history: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package ws
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/websocket"
+)
+
+// sendMessage sends a message request and returns the ID of the message it adds.
+func sendMessage(t *testing.T, conn *websocket.Conn, message map[string]string) string {
+	t.Helper()
+	if err := websocket.JSON.Send(conn, message); err != nil {
+		t.Fatal(err)
+	}
+	data, err := receive(conn, "Message")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := data[strings.Index(data, `"id":"`)+6:]
+
+	return id[:strings.Index(id, `"`)]
+}
+
+func TestRepliesUpdateTheRootOfTheThread(t *testing.T) {
+	_, server := newTestServer(t)
+	conn := dial(t, server, "alice")
+	if conn == nil {
+		t.FailNow()
+	}
+	defer conn.Close()
+	root := sendMessage(t, conn, map[string]string{"text": "hello"})
+
+	replyTo := root
+	for replies := 1; replies <= 2; replies++ {
+		reply := sendMessage(t, conn, map[string]string{"text": "reply", "replyTo": replyTo})
+		data, err := receive(conn, "Replies")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf(`{"id":%q,"replies":%d}`, root, replies); !strings.Contains(data, want) {
+			t.Errorf("got %s, want %s", data, want)
+		}
+		replyTo = reply
+	}
+}
+
+func TestAnonymousChangesAreForbidden(t *testing.T) {
+	_, server := newTestServer(t)
+	conn := dial(t, server, "alice")
+	if conn == nil {
+		t.FailNow()
+	}
+	defer conn.Close()
+	id := sendMessage(t, conn, map[string]string{"text": "hello"})
+
+	for _, request := range []Request{
+		{Type: "Edit", Data: []byte(`{"id":"` + id + `","text":"edited"}`)},
+		{Type: "Delete", Data: []byte(`{"id":"` + id + `"}`)},
+		{Type: "Reaction", Data: []byte(`{"id":"` + id + `","emoji":"👍"}`)},
+	} {
+		if err := websocket.JSON.Send(conn, request); err != nil {
+			t.Fatal(err)
+		}
+		data, err := receive(conn, "Error")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(data, "alice is not authenticated") {
+			t.Errorf("%s request got %s, want it forbidden", request.Type, data)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package ws
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	ClosePolicyViolation = 1008
+	CloseInternalError   = 1011
+)
+
+var (
+	SessionQueueSize = 64
+	WriteTimeout     = 10 * time.Second
+)
+
+// Session is a single websocket connection of a user, a user can have many sessions open in the same room.
+// Every write to the connection goes through the session queue and its writer goroutine, so a slow client
+// never blocks the hub; a client that falls behind or fails a write is evicted.
+type Session struct {
+	ID       string
+	RoomName string
+	UserName string
+	Conn     *websocket.Conn
+	// Authenticated is set when UserName is the user name of an auth record, rather than one picked by an
+	// anonymous client.
+	Authenticated bool
+
+	netConn     net.Conn // The connection under Conn, closed without the close frame Conn.Close would send.
+	queue       chan Frame
+	done        chan struct{}
+	stopped     chan struct{}
+	closeOnce   sync.Once
+	closeCode   int
+	closeReason string
+}
+
+func newSession(roomName, userName string, conn *websocket.Conn) *Session {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+
+	return &Session{
+		ID:       hex.EncodeToString(id),
+		RoomName: roomName,
+		UserName: userName,
+		Conn:     conn,
+		queue:    make(chan Frame, SessionQueueSize),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+func (s *Session) RemoteAddr() string {
+	if req := s.Conn.Request(); req != nil {
+		return req.RemoteAddr
+	}
+
+	return ""
+}
+
+func (s *Session) Send(event Event) error {
+	frame, err := NewFrame(event)
+	if err != nil {
+		return err
+	}
+	s.enqueue(frame)
+
+	return nil
+}
+
+func (s *Session) enqueue(frame Frame) {
+	select {
+	case <-s.done:
+	case s.queue <- frame:
+	default:
+		s.Evict(ClosePolicyViolation, fmt.Sprintf("outbound queue full (%d frames)", SessionQueueSize))
+	}
+}
+
+// Evict closes the session with the given close code and reason, which in turn ends its read loop and removes
+// it from the hub.
+func (s *Session) Evict(code int, reason string) {
+	s.closeOnce.Do(func() {
+		log.Printf("ws: evicting session %s of %s from room %s: %s\n", s.ID, s.UserName, s.RoomName, reason)
+		s.closeCode, s.closeReason = code, reason
+		close(s.done)
+	})
+}
+
+func (s *Session) Close() {
+	s.closeOnce.Do(func() {
+		s.closeCode = CloseNormal
+		close(s.done)
+	})
+}
+
+// writeLoop is the only writer of the connection once started, it sends queued frames and pings, and on
+// close sends the close frame before closing the connection.
+func (s *Session) writeLoop() {
+	defer close(s.stopped)
+	defer s.closeConn()
+
+	ticker := time.NewTicker(PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			payload := binary.BigEndian.AppendUint16(nil, uint16(s.closeCode))
+			_ = s.write(websocket.CloseFrame, append(payload, s.closeReason...))
+
+			return
+		case <-ticker.C:
+			if err := s.write(websocket.PingFrame, nil); err != nil {
+				s.Evict(CloseInternalError, fmt.Sprintf("ping error: %s", err))
+
+				return
+			}
+		case frame := <-s.queue:
+			if err := s.write(websocket.TextFrame, frame); err != nil {
+				s.Evict(CloseInternalError, fmt.Sprintf("write error: %s", err))
+
+				return
+			}
+		}
+	}
+}
+
+// closeConn closes the connection once its close frame, with the code and reason, was written. Conn.Close would
+// write another one with the normal code, which the client could read instead.
+func (s *Session) closeConn() {
+	if s.netConn != nil {
+		_ = s.netConn.Close()
+
+		return
+	}
+	_ = s.Conn.Close()
+}
+
+func (s *Session) write(payloadType byte, data []byte) error {
+	if err := s.Conn.SetWriteDeadline(time.Now().Add(WriteTimeout)); err != nil {
+		return err
+	}
+	s.Conn.PayloadType = payloadType
+	defer func() { s.Conn.PayloadType = websocket.TextFrame }()
+	_, err := s.Conn.Write(data)
+
+	return err
+}
+
+// wait blocks until the writer goroutine has flushed the close frame.
+func (s *Session) wait() {
+	<-s.stopped
+}
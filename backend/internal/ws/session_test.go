@@ -0,0 +1,101 @@
+package ws
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type rawFrame struct {
+	opcode  byte
+	payload []byte
+}
+
+// readFrames reads the unmasked frames the server sends until it closes the connection.
+func readFrames(reader *bufio.Reader) ([]rawFrame, error) {
+	frames := []rawFrame{}
+	for {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return frames, nil
+			}
+
+			return frames, err
+		}
+		length := uint64(header[1] & 0x7f)
+		switch length {
+		case 126:
+			extended := make([]byte, 2)
+			if _, err := io.ReadFull(reader, extended); err != nil {
+				return frames, err
+			}
+			length = uint64(binary.BigEndian.Uint16(extended))
+		case 127:
+			extended := make([]byte, 8)
+			if _, err := io.ReadFull(reader, extended); err != nil {
+				return frames, err
+			}
+			length = binary.BigEndian.Uint64(extended)
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			return frames, err
+		}
+		frames = append(frames, rawFrame{opcode: header[0] & 0x0f, payload: payload})
+	}
+}
+
+func TestEvictionSendsOneCloseFrame(t *testing.T) {
+	IdleTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { IdleTimeout = 0 })
+	_, server := newTestServer(t)
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	handshake := "GET /?userName=alice HTTP/1.1\r\nHost: " + conn.RemoteAddr().String() + "\r\n" +
+		"Upgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+		"Sec-WebSocket-Version: 13\r\nOrigin: " + server.URL + "\r\n\r\n"
+	if _, err := fmt.Fprint(conn, handshake); err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake status = %d", response.StatusCode)
+	}
+
+	frames, err := readFrames(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closes := []rawFrame{}
+	for _, frame := range frames {
+		if frame.opcode == 0x8 {
+			closes = append(closes, frame)
+		}
+	}
+	if len(closes) != 1 {
+		t.Fatalf("got %d close frames, want 1", len(closes))
+	}
+	code, reason := binary.BigEndian.Uint16(closes[0].payload), string(closes[0].payload[2:])
+	if code != CloseGoingAway || reason != "idle timeout" {
+		t.Errorf("close frame = %d %q, want %d %q", code, reason, CloseGoingAway, "idle timeout")
+	}
+}
@@ -0,0 +1,44 @@
+package ws
+
+import (
+	"copuchat/internal/store"
+	"fmt"
+)
+
+type ThreadRequest struct {
+	ID string `json:"id"`
+	store.Page
+}
+
+// Replies is the data of a Replies event, the reply count of a message after a reply was added to its thread.
+type Replies struct {
+	ID      string `json:"id"`
+	Replies int    `json:"replies"`
+}
+
+func publishReplies(db store.Store, roomName, id string) error {
+	replies, err := db.GetReplies(roomName, []string{id})
+	if err != nil {
+		return fmt.Errorf("ws: error getting replies of %s: %w", id, err)
+	}
+	if err := Publish(db, roomName, Event{Type: "Replies", Data: Replies{ID: id, Replies: replies[id]}}); err != nil {
+		return fmt.Errorf("ws: error broadcasting replies: %w", err)
+	}
+
+	return nil
+}
+
+func sendThread(db store.Store, session *Session, request ThreadRequest) error {
+	if _, _, err := store.ParseID(request.ID); err != nil {
+		return err
+	}
+	thread, err := db.GetThread(session.RoomName, request.ID, request.Page)
+	if err != nil {
+		return fmt.Errorf("ws: error getting thread: %w", err)
+	}
+	if err := session.Send(Event{Type: "Thread", Data: thread}); err != nil {
+		return fmt.Errorf("ws: error sending thread: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package ws
+
+import (
+	"copuchat/internal/store"
+	"errors"
+	"fmt"
+)
+
+// Reasons a topic change is rejected.
+const (
+	TopicLockedReason          = "topic_locked"
+	TopicNotAllowedReason      = "not_owner_or_moderator"
+	TopicUnauthenticatedReason = "unauthenticated"
+)
+
+// TopicError is returned when a user can not change the topic of a room under its topic policy.
+type TopicError struct {
+	RoomName string `json:"roomName"`
+	UserName string `json:"userName,omitempty"`
+	Policy   string `json:"policy"`
+	Reason   string `json:"reason"`
+}
+
+func (e *TopicError) Error() string {
+	return fmt.Sprintf("%s: %s can not set the topic of %s, %s", ErrForbidden, e.UserName, e.RoomName, e.Reason)
+}
+
+func (e *TopicError) Unwrap() error {
+	return ErrForbidden
+}
+
+// CanSetTopic returns nil when userName may change the topic of the room under its topic policy, otherwise a
+// TopicError with the reason. userName is the authenticated user, empty when there is none.
+func CanSetTopic(db store.Store, roomName, userName string) error {
+	policy, err := db.GetTopicPolicy(roomName)
+	if err != nil {
+		return fmt.Errorf("ws: error getting topic policy: %w", err)
+	}
+	if userName == "" {
+		return &TopicError{RoomName: roomName, Policy: policy, Reason: TopicUnauthenticatedReason}
+	}
+	switch policy {
+	case store.TopicOpen:
+		return nil
+	case store.TopicLocked:
+		return &TopicError{RoomName: roomName, UserName: userName, Policy: policy, Reason: TopicLockedReason}
+	}
+	owner, err := db.GetRoomOwner(roomName)
+	if err != nil && !errors.Is(err, store.ErrNil) {
+		return fmt.Errorf("ws: error getting room owner: %w", err)
+	}
+	if owner != "" && owner == userName {
+		return nil
+	}
+	isModerator, err := db.IsModerator(roomName, userName)
+	if err != nil {
+		return fmt.Errorf("ws: error checking moderators: %w", err)
+	}
+	if !isModerator {
+		return &TopicError{RoomName: roomName, UserName: userName, Policy: policy, Reason: TopicNotAllowedReason}
+	}
+
+	return nil
+}
+
+// SetTopic changes the topic of the room and broadcasts it, authorized tells whether the topic policy is
+// bypassed, as it is for admins.
+func SetTopic(db store.Store, roomName, userName, topic string, authorized bool) (store.TopicChange, error) {
+	if !authorized {
+		if err := CanSetTopic(db, roomName, userName); err != nil {
+			return store.TopicChange{}, err
+		}
+	}
+	change, err := db.SetTopic(roomName, topic, userName)
+	if err != nil {
+		return store.TopicChange{}, fmt.Errorf("ws: error setting topic: %w", err)
+	}
+	if err := Publish(db, roomName, Event{Type: "Topic", Data: change.Topic}); err != nil {
+		return change, fmt.Errorf("ws: error broadcasting topic: %w", err)
+	}
+
+	return change, nil
+}
@@ -0,0 +1,123 @@
+package ws
+
+import (
+	"bytes"
+	"copuchat/internal/store"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	// UpdateInterval is the least time between two pushes of the SubRooms and ActiveUsers of a room, changes
+	// meanwhile are coalesced into the next push.
+	UpdateInterval = 2 * time.Second
+	// UpdateCheckInterval is how often hubs check their room for changes no event announces, like users that
+	// stopped being active chatters.
+	UpdateCheckInterval = 1 * time.Minute
+)
+
+const updateCacheKeyPrefix = "update:"
+
+var updates = struct {
+	sync.Mutex
+	pending map[string]bool
+}{pending: map[string]bool{}}
+
+// scheduleUpdates schedules a push to roomName, whose users changed, and to its parent room, whose sub rooms
+// scores changed.
+func scheduleUpdates(db store.Store, roomName string) {
+	scheduleUpdate(db, roomName)
+	if roomName != "" {
+		scheduleUpdate(db, store.ParentRoom(roomName))
+	}
+}
+
+func scheduleUpdate(db store.Store, roomName string) {
+	updates.Lock()
+	defer updates.Unlock()
+
+	if updates.pending[roomName] {
+		return
+	}
+	updates.pending[roomName] = true
+	time.AfterFunc(UpdateInterval, func() {
+		updates.Lock()
+		delete(updates.pending, roomName)
+		updates.Unlock()
+		if err := pushUpdates(db, roomName); err != nil {
+			log.Printf("%s\n", err)
+		}
+	})
+}
+
+// pushUpdates publishes the SubRooms and ActiveUsers events of roomName that changed since their last push,
+// from this or any other instance.
+func pushUpdates(db store.Store, roomName string) error {
+	events, err := updateEvents(db, roomName)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		frame, err := NewFrame(event)
+		if err != nil {
+			return err
+		}
+		cacheKey := updateCacheKeyPrefix + event.Type + ":" + roomName
+		last, err := db.GetCache(cacheKey)
+		if err != nil && !errors.Is(err, store.ErrNil) {
+			return fmt.Errorf("ws: error getting last %s event: %w", event.Type, err)
+		}
+		if bytes.Equal(last, frame) {
+			continue
+		}
+		// Only a published event is recorded, so one that failed to publish is retried on the next push.
+		if err := db.Publish(roomName, frame); err != nil {
+			return fmt.Errorf("ws: error publishing %s event: %w", event.Type, err)
+		}
+		if err := db.SetCache(cacheKey, frame, cacheExpirationTime); err != nil {
+			return fmt.Errorf("ws: error saving last %s event: %w", event.Type, err)
+		}
+	}
+
+	return nil
+}
+
+func updateEvents(db store.Store, roomName string) ([]Event, error) {
+	subRooms, err := db.GetTopSubRooms(roomName)
+	if err != nil {
+		return nil, fmt.Errorf("ws: error getting sub rooms: %w", err)
+	}
+	if subRooms == nil {
+		subRooms = []store.ActiveUsersLen{}
+	}
+	online, err := db.GetOnlineUsers(roomName)
+	if err != nil {
+		return nil, fmt.Errorf("ws: error getting online users: %w", err)
+	}
+	chatters, err := db.GetActiveUsers(roomName)
+	if err != nil {
+		return nil, fmt.Errorf("ws: error getting active users: %w", err)
+	}
+
+	return []Event{
+		{Type: "SubRooms", Data: subRooms},
+		{Type: "ActiveUsers", Data: store.Presence{Online: online, Chatters: chatters}},
+	}, nil
+}
+
+// checkUpdates schedules a push to the hub room every UpdateCheckInterval until stop is closed.
+func (h *Hub) checkUpdates(db store.Store, stop <-chan struct{}) {
+	ticker := time.NewTicker(UpdateCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			scheduleUpdate(db, h.RoomName)
+		}
+	}
+}
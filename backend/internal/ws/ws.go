@@ -1,16 +1,17 @@
 package ws
 
 import (
-	"copuchat/internal/redis"
+	"copuchat/internal/store"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
-	"strings"
+	"net"
+	"net/http"
 	"sync"
 	"time"
 
-	redigo "github.com/gomodule/redigo/redis"
 	"golang.org/x/net/websocket"
 	"mvdan.cc/xurls/v2"
 )
@@ -18,130 +19,189 @@ import (
 const cacheExpirationTime = 12 * time.Hour
 
 type Event struct {
-	Type string `json:"type"` // Messages | Message | Preview | Topic | Error.
+	// Messages | Resume | History | Thread | Message | Replies | MessageEdited | MessageDeleted | Reaction | Preview |
+	// Topic | Join | Leave | SubRooms | ActiveUsers | Error.
+	Type string `json:"type"`
 	Data any    `json:"data"`
 }
 
-var Hubs = map[string]*Hub{}
+// Frame is an Event encoded once, every session it is broadcast to writes the same bytes as-is.
+type Frame []byte
+
+func NewFrame(event Event) (Frame, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("ws: error encoding %s event: %w", event.Type, err)
+	}
+
+	return data, nil
+}
 
 type Hub struct {
 	RoomName string
-	Conns    map[string]*websocket.Conn
+	Sessions map[string]map[string]*Session // userName -> session ID -> session.
+	sub      store.Subscription
+	stop     chan struct{}
+	// ready is closed once the join that created the hub subscribed it, err tells whether it failed. joining
+	// counts the joins waiting for it, it is guarded by the Registry lock.
+	ready   chan struct{}
+	err     error
+	joining int
 	sync.RWMutex
 }
 
-func GetHub(roomName string) *Hub {
-	if hub, ok := Hubs[roomName]; ok {
-		return hub
-	}
+func (h *Hub) UsersLen() int {
+	h.RLock()
+	defer h.RUnlock()
 
-	return &Hub{RoomName: roomName, Conns: map[string]*websocket.Conn{}}
+	return len(h.Sessions)
 }
 
-func (h *Hub) Broadcast(event Event, except []string) error {
+func (h *Hub) SessionsLen() int {
 	h.RLock()
 	defer h.RUnlock()
-	for userName, conn := range h.Conns {
-		skip := false
-		for _, exceptName := range except {
-			if exceptName == userName {
-				skip = true
+	n := 0
+	for _, sessions := range h.Sessions {
+		n += len(sessions)
+	}
 
-				break
-			}
-		}
-		if skip {
-			continue
-		}
-		if err := websocket.JSON.Send(conn, event); err != nil {
-			return err
+	return n
+}
+
+func (h *Hub) broadcastFrame(frame Frame) {
+	h.RLock()
+	defer h.RUnlock()
+	for _, sessions := range h.Sessions {
+		for _, session := range sessions {
+			session.enqueue(frame)
 		}
 	}
-
-	return nil
 }
 
-func Handler(roomName, userName string) websocket.Handler {
-	return func(conn *websocket.Conn) {
-		defer conn.Close()
-
-		hub := GetHub(roomName)
-		hub.Lock()
-		hub.Conns[userName] = conn
-		hub.Unlock()
-		defer func() {
-			hub.Lock()
-			defer hub.Unlock()
-			delete(hub.Conns, userName)
-			if len(hub.Conns) == 0 {
-				delete(Hubs, roomName)
-			}
-		}()
-		if err := sendInitialData(conn, roomName); err != nil {
+// Handler serves a websocket session of userName in roomName, authenticated tells whether userName was
+// authenticated or just picked by an anonymous client. When lastID is set the session resumes after that message
+// instead of receiving the latest messages snapshot.
+func (r *Registry) Handler(roomName, userName string, authenticated bool, lastID string) http.Handler {
+	db := r.db
+	serve := func(conn *websocket.Conn, netConn net.Conn) {
+		session := newSession(roomName, userName, conn)
+		session.netConn = netConn
+		session.Authenticated = authenticated
+		hub, err := r.Join(roomName, session)
+		var roomErr *store.RoomNameError
+		if errors.As(err, &roomErr) {
+			_ = websocket.JSON.Send(conn, Event{Type: "Error", Data: roomErr})
+
+			return
+		}
+		if err != nil {
+			log.Printf("%s\n", err)
+			_ = websocket.JSON.Send(conn, Event{Type: "Error", Data: "chat unavailable"})
+
+			return
+		}
+		go session.writeLoop()
+		go keepOnline(db, session)
+		defer session.wait()
+		defer session.Close()
+		defer r.Leave(hub, session)
+		if err := sendInitialData(db, session, lastID); err != nil {
 			log.Printf("%s\n", err)
 		}
 
+		var idle *time.Timer
+		if IdleTimeout > 0 {
+			idle = time.AfterFunc(IdleTimeout, func() { session.Evict(CloseGoingAway, "idle timeout") })
+			defer idle.Stop()
+		}
 		for {
-			var message *redis.Message
-			if err := websocket.JSON.Receive(conn, &message); err != nil {
-				if !errors.Is(err, io.EOF) {
+			var data []byte
+			if err := websocket.Message.Receive(conn, &data); err != nil {
+				if isTimeout(err) {
+					session.Evict(CloseGoingAway, "read timeout")
+				} else if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
 					log.Printf("ws: error reading conn: %s\n", err)
 				}
 
 				break
 			}
-			if message == nil || message.Text == "" {
-				continue
+			if idle != nil {
+				idle.Reset(IdleTimeout)
 			}
-			message.UserName = userName
-			if err := handleMessage(hub, message, roomName); err != nil {
-				log.Printf("ws: error handling message: %s\n", err)
-				if err := websocket.JSON.Send(conn, Event{Type: "Error", Data: err.Error()}); err != nil {
+			if err := handleRequest(db, hub, session, data); err != nil {
+				log.Printf("ws: error handling request: %s\n", err)
+				if err := session.Send(Event{Type: "Error", Data: err.Error()}); err != nil {
 					log.Printf("ws: error sending error message: %s\n", err)
 				}
 			}
 		}
 	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		writer := &deadlineResponseWriter{ResponseWriter: w, timeout: ReadTimeout}
+		websocket.Handler(func(conn *websocket.Conn) { serve(conn, writer.conn) }).ServeHTTP(writer, req)
+	})
 }
 
-func sendInitialData(conn *websocket.Conn, roomName string) error {
-	messages, err := redis.GetLastMessages(roomName)
-	if err != nil && !errors.Is(err, redigo.ErrNil) {
-		return fmt.Errorf("ws: error getting room messages: %w", err)
-	}
-	topic, err := redis.GetTopic(roomName)
-	if err != nil && !errors.Is(err, redigo.ErrNil) {
+func sendInitialData(db store.Store, session *Session, lastID string) error {
+	roomName := session.RoomName
+	topic, err := db.GetTopic(roomName)
+	if err != nil && !errors.Is(err, store.ErrNil) {
 		return fmt.Errorf("ws: error getting room topic: %w", err)
 	}
 
-	if err := websocket.JSON.Send(conn, Event{Type: "Messages", Data: messages}); err != nil {
-		return fmt.Errorf("ws: error sending room messages: %w", err)
+	if lastID != "" {
+		if err := sendMissedMessages(db, session, lastID); err != nil {
+			return err
+		}
+	} else {
+		messages, err := db.GetLastMessages(roomName)
+		if err != nil && !errors.Is(err, store.ErrNil) {
+			return fmt.Errorf("ws: error getting room messages: %w", err)
+		}
+		if err := session.Send(Event{Type: "Messages", Data: messages}); err != nil {
+			return fmt.Errorf("ws: error sending room messages: %w", err)
+		}
 	}
-	if err := websocket.JSON.Send(conn, Event{Type: "Topic", Data: topic}); err != nil {
+	if err := session.Send(Event{Type: "Topic", Data: topic}); err != nil {
 		return fmt.Errorf("ws: error sending room topic: %w", err)
 	}
+	events, err := updateEvents(db, roomName)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		if err := session.Send(event); err != nil {
+			return fmt.Errorf("ws: error sending %s: %w", event.Type, err)
+		}
+	}
 
 	return nil
 }
 
-func handleMessage(hub *Hub, message *redis.Message, roomName string) error {
-	newRoom, err := redis.AddMessage(message, roomName)
+func handleMessage(db store.Store, hub *Hub, session *Session, message *store.Message) error {
+	roomName := hub.RoomName
+	newRoom, err := db.AddMessage(message, roomName, session.Authenticated)
 	if err != nil {
-		return fmt.Errorf("ws: error adding message: to redis %w", err)
+		return fmt.Errorf("ws: error adding message: %w", err)
 	}
-	if err := hub.Broadcast(Event{Type: "Message", Data: message}, nil); err != nil {
+	if err := Publish(db, roomName, Event{Type: "Message", Data: message}); err != nil {
 		return fmt.Errorf("ws: error broadcasting: %w", err)
 	}
+	if message.ReplyTo != "" {
+		if err := publishReplies(db, roomName, message.ReplyTo); err != nil {
+			return err
+		}
+	}
 	if newRoom {
-		path := strings.Split(roomName, "/")
-		parentRoom := strings.Join(path[:len(path)-1], "/")
-		if err := GetHub(parentRoom).Broadcast(Event{Type: "Message", Data: message}, nil); err != nil {
+		if err := Publish(db, store.ParentRoom(roomName), Event{Type: "Message", Data: message}); err != nil {
 			return fmt.Errorf("ws: error broadcasting to parent room: %w", err)
 		}
 	}
+	scheduleUpdates(db, roomName)
 
 	go func() {
-		if err := broadcastLinkPreview(hub, message); err != nil {
+		if err := broadcastLinkPreview(db, hub, session, message); err != nil {
 			log.Printf("ws: error broadcasting open graph: %s\n", err)
 		}
 	}()
@@ -149,12 +209,12 @@ func handleMessage(hub *Hub, message *redis.Message, roomName string) error {
 	return nil
 }
 
-func broadcastLinkPreview(hub *Hub, message *redis.Message) error {
+func broadcastLinkPreview(db store.Store, hub *Hub, session *Session, message *store.Message) error {
 	url := xurls.Relaxed().FindString(message.Text)
 	if url == "" {
 		return nil
 	}
-	graph, err := LinkPreviewGraph(url, hub, message.UserName)
+	graph, err := LinkPreviewGraph(db, url, session.RemoteAddr())
 	if err != nil {
 		return err
 	}
@@ -162,5 +222,5 @@ func broadcastLinkPreview(hub *Hub, message *redis.Message) error {
 		return nil
 	}
 
-	return hub.Broadcast(Event{Type: "Preview", Data: graph}, nil)
+	return Publish(db, hub.RoomName, Event{Type: "Preview", Data: graph})
 }
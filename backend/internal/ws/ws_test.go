@@ -0,0 +1,82 @@
+package ws
+
+import (
+	"copuchat/internal/store"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// BenchmarkBroadcast compares encoding an event once for every session, as broadcastFrame does, against encoding
+// it once per session, as broadcasting did before frames.
+func BenchmarkBroadcast(b *testing.B) {
+	for _, n := range []int{1000, 10000} {
+		n := n
+		b.Run(fmt.Sprintf("sessions=%d/encode=once", n), func(b *testing.B) {
+			benchmarkBroadcast(b, n, func(hub *Hub, event Event) error {
+				frame, err := NewFrame(event)
+				if err != nil {
+					return err
+				}
+				hub.broadcastFrame(frame)
+
+				return nil
+			})
+		})
+		b.Run(fmt.Sprintf("sessions=%d/encode=per-session", n), func(b *testing.B) {
+			benchmarkBroadcast(b, n, broadcastPerSession)
+		})
+	}
+}
+
+// broadcastPerSession is the baseline, it encodes the event for every session it sends it to.
+func broadcastPerSession(hub *Hub, event Event) error {
+	hub.RLock()
+	defer hub.RUnlock()
+	for _, sessions := range hub.Sessions {
+		for _, session := range sessions {
+			if err := session.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// benchmarkBroadcast fans a message event out to n in-memory sessions with broadcast, each session drained by
+// its own goroutine as its writer would, and waits for every session to receive it.
+func benchmarkBroadcast(b *testing.B, n int, broadcast func(*Hub, Event) error) {
+	b.Helper()
+	hub := &Hub{RoomName: "lobby", Sessions: map[string]map[string]*Session{}}
+	var delivered sync.WaitGroup
+	for i := 0; i < n; i++ {
+		session := newSession(hub.RoomName, fmt.Sprintf("user%d", i), nil)
+		hub.Sessions[session.UserName] = map[string]*Session{session.ID: session}
+		go func() {
+			for range session.queue {
+				delivered.Done()
+			}
+		}()
+	}
+	b.Cleanup(func() {
+		for _, sessions := range hub.Sessions {
+			for _, session := range sessions {
+				close(session.queue)
+			}
+		}
+	})
+
+	message := store.Message{ID: "1700000000000-0", UserName: "user0", Text: "hello everyone", Timestamp: time.Now().UnixMilli()}
+	event := Event{Type: "Message", Data: message}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		delivered.Add(n)
+		if err := broadcast(hub, event); err != nil {
+			b.Fatal(err)
+		}
+		delivered.Wait()
+	}
+}
@@ -1,20 +1,51 @@
 package pb
 
 import (
+	"context"
 	"copuchat/internal/api"
+	"copuchat/internal/archive"
+	"copuchat/internal/redis"
+	"copuchat/internal/retention"
+	"copuchat/internal/seed"
+	"copuchat/internal/store"
+	"errors"
 	"log"
+	"os"
 	"strings"
 
 	"github.com/labstack/echo/v5"
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cobra"
 )
 
 func NewApp() *pocketbase.PocketBase {
 	app := pocketbase.New()
+	store.AllowTopLevelRooms = os.Getenv("ALLOW_TOP_LEVEL_ROOMS") != "false"
+	app.RootCmd.AddCommand(seedCommand())
 
 	app.OnBeforeServe().Add(func(e *core.ServeEvent) error {
-		for _, r := range api.Routes(app) {
+		ctx, cancel := context.WithCancel(context.Background())
+		app.OnTerminate().Add(func(e *core.TerminateEvent) error {
+			cancel()
+
+			return nil
+		})
+		archive.SearchIndex = os.Getenv("SEARCH") == "archive"
+		redis.SearchIndex = !archive.SearchIndex
+		// The archive serves the trimmed messages, the store keeps their reactions and threads for it.
+		store.PruneTrimmed = false
+		var db store.Store = newStore(ctx)
+		go seed.Bootstrap(ctx, db)
+		archiver := archive.New(app, db)
+		if err := archiver.EnsureCollection(); err != nil {
+			return err
+		}
+		go archiver.Run(ctx)
+		db = archive.NewStore(db, archiver)
+		go retention.Run(ctx, db)
+
+		for _, r := range api.Routes(app, db) {
 			_, _ = e.Router.AddRoute(r)
 		}
 
@@ -37,3 +68,56 @@ func NewApp() *pocketbase.PocketBase {
 
 	return app
 }
+
+func newStore(ctx context.Context) store.Store {
+	if os.Getenv("STORE") == "memory" {
+		log.Println("STORE env var set to memory, chat data will not be persisted")
+
+		return store.NewMemory()
+	}
+
+	config := redis.DefaultConfig()
+	if url := os.Getenv("REDIS_URL"); url != "" {
+		config.URL = url
+	} else {
+		log.Println("REDIS_URL env var not set, using localhost")
+	}
+	db, err := redis.Connect(ctx, config)
+	if err != nil {
+		log.Printf("%s, starting in degraded mode\n", err)
+	}
+
+	return db
+}
+
+func seedCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "seed [file]",
+		Short: "Creates the root room and the rooms of a YAML or JSON seed file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if os.Getenv("STORE") == "memory" {
+				return errors.New("seed: error, the memory store only lives in the server process, seed it through the api")
+			}
+			file, err := seed.Load(args[0])
+			if err != nil {
+				return err
+			}
+			db := newStore(cmd.Context())
+			if err := db.Health(); err != nil {
+				return err
+			}
+			if _, err := db.CreateRoom(""); err != nil {
+				return err
+			}
+			result, err := seed.Apply(db, file)
+			if err != nil {
+				return err
+			}
+			log.Printf("seed: created %d rooms %v, %d already existed %v\n",
+				len(result.Created), result.Created, len(result.Existed), result.Existed)
+
+			return nil
+		},
+	}
+}
@@ -1,3 +1,13 @@
 import PocketBase from "pocketbase";
 
 export const client = new PocketBase("http://localhost:8090");
+
+// The websocket URL of a room, authenticated with the auth token when the user is logged in, since browsers
+// can't set headers on websocket requests.
+export const wsUrl = (room: string | undefined, userName: string) => {
+  const params = new URLSearchParams({ userName });
+  if (client.authStore.isValid) {
+    params.set("token", client.authStore.token);
+  }
+  return `ws://localhost:8090/ws/${room ?? ""}?${params}`;
+};
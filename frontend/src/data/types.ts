@@ -10,7 +10,18 @@ export type ChatEvent =
 export type WebSocketResponse =
   | ChatEvent
   | WebSocketEvent<"Messages", Message[]>
+  | WebSocketEvent<"Resume", Resume>
+  | WebSocketEvent<"History", History>
+  | WebSocketEvent<"Thread", Thread>
+  | WebSocketEvent<"Replies", Replies>
+  | WebSocketEvent<"MessageEdited", Message>
+  | WebSocketEvent<"MessageDeleted", Message>
+  | WebSocketEvent<"Reaction", Reactions>
   | WebSocketEvent<"Topic", string>
+  | WebSocketEvent<"Join", UserPresence>
+  | WebSocketEvent<"Leave", UserPresence>
+  | WebSocketEvent<"SubRooms", SubRoom[]>
+  | WebSocketEvent<"ActiveUsers", Presence>
   | WebSocketEvent<"Error", string>
   | null;
 
@@ -18,12 +29,77 @@ export type RoomPreview = {
   name: string;
   room: string;
   activeUsersLength: number;
+  onlineUsersLength?: number;
+};
+
+export type TopicChange = {
+  topic: string;
+  previous: string;
+  setBy?: string;
+  setAt: number;
+};
+
+export type SubRoom = {
+  roomName: string;
+  activeUsersLength: number;
+  onlineUsersLength: number;
+};
+
+export type UserPresence = {
+  userName: string;
+};
+
+export type Presence = {
+  online: string[];
+  chatters: string[];
 };
 
 export type Message = {
+  id: string;
   userName: string;
   text: string;
   timestamp: number;
+  replyTo?: string;
+  replies?: number;
+  editedAt?: number;
+  deleted?: boolean;
+  reactions?: Reaction[];
+};
+
+export type Reaction = {
+  emoji: string;
+  count: number;
+  userNames: string[];
+};
+
+export type Reactions = {
+  id: string;
+  reactions: Reaction[];
+};
+
+export type Replies = {
+  id: string;
+  replies: number;
+};
+
+export type Resume = {
+  lastId: string;
+  messages: Message[];
+  gap: boolean;
+};
+
+export type History = {
+  before?: string;
+  after?: string;
+  limit: number;
+  messages: Message[];
+  hasMore: boolean;
+};
+
+export type Thread = {
+  parent: Message;
+  messages: Message[];
+  hasMore: boolean;
 };
 
 export type LinkPreview = {
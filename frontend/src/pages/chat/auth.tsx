@@ -20,6 +20,8 @@ const Auth = () => {
       const userData = await client
         .collection("users")
         .authWithPassword(email, password);
+      // The server chats as the user name of the auth record.
+      setAtomUserName(userData.record.username);
       navigate("/app");
     } catch (e) {
       setError((e as Error).message);
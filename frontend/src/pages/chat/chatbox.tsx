@@ -1,4 +1,5 @@
 import { userNameAtom } from "../../data/atoms";
+import { wsUrl } from "../../data/pb";
 import {
   ChatEvent,
   LinkPreview,
@@ -130,6 +131,24 @@ const LinkPreviewMessage = ({
   );
 };
 
+// compareIds orders message IDs, made of the milliseconds they were added at and a sequence number.
+const compareIds = (a: string, b: string) => {
+  const [aMs, aSeq] = a.split("-").map(Number);
+  const [bMs, bSeq] = b.split("-").map(Number);
+  return aMs - bMs || aSeq - bSeq;
+};
+
+// insertMessage adds a message before the first newer one, resumed messages can be older than the ones shown.
+const insertMessage = (chat: ChatEvent[], message: Message): ChatEvent[] => {
+  const i = chat.findIndex(
+    (m) => m.type === "Message" && compareIds(m.data.id, message.id) > 0
+  );
+  const entry: ChatEvent = { type: "Message", data: message };
+  return i < 0
+    ? [...chat, entry]
+    : [...chat.slice(0, i), entry, ...chat.slice(i)];
+};
+
 const ChatBox = () => {
   const { "*": room } = useParams();
   const userName = useAtomValue(userNameAtom);
@@ -137,35 +156,119 @@ const ChatBox = () => {
   const [message, setMessage] = useState<string>("");
   const inputRef = useRef<HTMLDivElement>(null);
   const boxRef = useRef<HTMLDivElement>(null);
+  // The newest message received, a reconnection resumes after it so the messages missed meanwhile arrive.
+  const lastIdRef = useRef("");
+  // Set while the history is reloaded after a gap, the History answer then replaces the chat.
+  const reloadingRef = useRef(false);
   const { sendJsonMessage, lastJsonMessage, readyState } =
     useWebSocket<WebSocketResponse>(
-      `ws://localhost:8090/ws/${room}?userName=${encodeURIComponent(userName)}`,
+      wsUrl(room, userName),
       {
         share: true,
         retryOnError: true,
         shouldReconnect: () => true,
+        onOpen: () => {
+          if (lastIdRef.current)
+            sendJsonMessage({
+              type: "Resume",
+              data: { lastId: lastIdRef.current },
+            });
+        },
       }
     );
 
+  useEffect(() => {
+    lastIdRef.current = "";
+  }, [room]);
+
   useEffect(() => {
     if (lastJsonMessage == null) return;
     console.log(lastJsonMessage.data);
+    const addMessages = (messages: Message[]) => {
+      for (const m of messages)
+        if (!lastIdRef.current || compareIds(m.id, lastIdRef.current) > 0)
+          lastIdRef.current = m.id;
+      setChat((chat) => {
+        const seen = new Set(
+          chat.map((m) => (m.type === "Message" ? m.data.id : null))
+        );
+        const added = messages.filter((m) => m.text && !seen.has(m.id));
+        return added.reduce(insertMessage, chat);
+      });
+    };
     if (lastJsonMessage.type === "Message") {
       const message = lastJsonMessage.data;
-      if (message?.text) setChat((chat) => [...chat, lastJsonMessage]);
+      if (message) addMessages([message]);
     }
     if (lastJsonMessage.type === "Messages") {
       const messages = lastJsonMessage.data;
-      if (messages)
-        messages.forEach((m) =>
-          setChat((chat) => [...chat, { type: "Message", data: m }])
+      if (messages) addMessages(messages);
+    }
+    if (lastJsonMessage.type === "Resume") {
+      const resume = lastJsonMessage.data;
+      // Some missed messages are no longer retained, reload the history instead of showing it with a hole.
+      if (resume?.gap) {
+        reloadingRef.current = true;
+        sendJsonMessage({ type: "History", data: {} });
+      } else if (resume?.messages) addMessages(resume.messages);
+    }
+    if (lastJsonMessage.type === "History" && reloadingRef.current) {
+      reloadingRef.current = false;
+      const messages = lastJsonMessage.data?.messages;
+      if (messages) {
+        setChat([]);
+        addMessages(messages);
+      }
+    }
+    if (
+      lastJsonMessage.type === "MessageEdited" ||
+      lastJsonMessage.type === "MessageDeleted"
+    ) {
+      const message = lastJsonMessage.data;
+      if (message)
+        setChat((chat) =>
+          chat
+            .map((m): ChatEvent =>
+              m.type === "Message" && m.data.id === message.id
+                ? { type: "Message", data: message }
+                : m
+            )
+            .filter((m) => m.type !== "Message" || !m.data.deleted)
+        );
+    }
+    if (lastJsonMessage.type === "Reaction") {
+      const update = lastJsonMessage.data;
+      if (update)
+        setChat((chat) =>
+          chat.map((m): ChatEvent =>
+            m.type === "Message" && m.data.id === update.id
+              ? {
+                  type: "Message",
+                  data: { ...m.data, reactions: update.reactions },
+                }
+              : m
+          )
+        );
+    }
+    if (lastJsonMessage.type === "Replies") {
+      const update = lastJsonMessage.data;
+      if (update)
+        setChat((chat) =>
+          chat.map((m): ChatEvent =>
+            m.type === "Message" && m.data.id === update.id
+              ? {
+                  type: "Message",
+                  data: { ...m.data, replies: update.replies },
+                }
+              : m
+          )
         );
     }
     if (lastJsonMessage.type === "Preview") {
       const preview = lastJsonMessage.data;
       if (preview?.description) setChat((chat) => [...chat, lastJsonMessage]);
     }
-  }, [lastJsonMessage, setChat]);
+  }, [lastJsonMessage, setChat, sendJsonMessage]);
 
   useEffect(() => {
     if (boxRef.current === null) return;
@@ -190,7 +293,7 @@ const ChatBox = () => {
           {chat.map((m, i) =>
             m.type === "Message" ? (
               <TextEntry
-                key={i}
+                key={m.data.id || i}
                 message={m.data}
                 showName={
                   m.data.userName != (chat[i - 1]?.data as Message)?.userName
@@ -6,6 +6,7 @@ import {
   XIcon,
 } from "../../assets/icons";
 import { drawerAtom, userNameAtom } from "../../data/atoms";
+import { client, wsUrl } from "../../data/pb";
 import { WebSocketResponse } from "../../data/types";
 import Auth from "./auth";
 import ChatBox from "./chatbox";
@@ -64,7 +65,7 @@ const TopicHeader = () => {
   const [newTopic, setNewTopic] = useState("");
   const topicRef = useRef<HTMLParagraphElement>(null);
   const { lastJsonMessage } = useWebSocket<WebSocketResponse>(
-    `ws://localhost:8090/ws/${room}?userName=${encodeURIComponent(userName)}`,
+    wsUrl(room, userName),
     {
       share: true,
       retryOnError: true,
@@ -99,12 +100,18 @@ const TopicHeader = () => {
             onClick={async () => {
               setEditingTopic(false);
               if (newTopic === "") return;
-              // TODO: Send request to change topic
-              await fetch(`http://localhost:8090/topic/${room}`, {
-                method: "POST",
-                body: newTopic,
-              });
-              setTopic(newTopic);
+              const response = await fetch(
+                `http://localhost:8090/topic/${room}`,
+                {
+                  method: "POST",
+                  body: newTopic,
+                  headers: client.authStore.isValid
+                    ? { Authorization: client.authStore.token }
+                    : {},
+                }
+              );
+              if (response.ok) setTopic(newTopic);
+              else if (topicRef.current) topicRef.current.innerHTML = topic;
             }}
           />
           <HeaderButton
@@ -1,25 +1,35 @@
 import { Spinner } from "../../assets/icons";
+import { userNameAtom } from "../../data/atoms";
+import { wsUrl } from "../../data/pb";
+import { SubRoom, WebSocketResponse } from "../../data/types";
+import { useAtomValue } from "jotai";
 import { useEffect, useState } from "react";
-import { useQuery } from "react-query";
+import { useQuery, useQueryClient } from "react-query";
 import { Link, useParams } from "react-router-dom";
-
-type SubRoomEntry = {
-  roomName: string;
-  activeUsersLength: number;
-};
+import useWebSocket from "react-use-websocket";
 
 const SubRooms = () => {
   const { "*": room } = useParams();
+  const userName = useAtomValue(userNameAtom);
   const [newSubRoom, setNewSubRoom] = useState("");
-  const { isLoading, error, data, refetch } = useQuery<SubRoomEntry[]>({
+  const queryClient = useQueryClient();
+  const { isLoading, error, data, refetch } = useQuery<SubRoom[]>({
     queryKey: ["subRooms"],
     queryFn: async () =>
       (await fetch(`http://localhost:8090/sub_rooms/${room}`)).json(),
-    refetchInterval: 30000,
   });
+  // Shares the chat box connection, which pushes the sub rooms when they change.
+  const { lastJsonMessage } = useWebSocket<WebSocketResponse>(
+    wsUrl(room, userName),
+    { share: true }
+  );
   useEffect(() => {
     refetch();
   }, [room]);
+  useEffect(() => {
+    if (lastJsonMessage?.type === "SubRooms")
+      queryClient.setQueryData(["subRooms"], lastJsonMessage.data);
+  }, [lastJsonMessage, queryClient]);
   /*const rooms: RoomPreview[] = [
     {
       name: "ronaldo",